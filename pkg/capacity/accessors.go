@@ -0,0 +1,198 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ResourceNames returns the names of the resources that were collected, in
+// alphabetical order
+func (cm *ClusterMetric) ResourceNames() []corev1.ResourceName {
+	return sortedResourceNames(cm.resources)
+}
+
+// Resource returns the totals of a resource across every node, or nil if
+// the resource was not collected
+func (cm *ClusterMetric) Resource(name corev1.ResourceName) *ResourceMetric {
+	return cm.resources[name]
+}
+
+// Nodes returns each node, sorted by name
+func (cm *ClusterMetric) Nodes() []*NodeMetric {
+	return cm.getSortedNodeMetrics("name")
+}
+
+// Unscheduled returns the pods that have not been scheduled to a node,
+// collected into a node named UnscheduledNodeName, or nil if pending pods
+// were not requested or there are none
+func (cm *ClusterMetric) Unscheduled() *NodeMetric {
+	return cm.unscheduled
+}
+
+// PodCount returns the number of pods scheduled to the cluster's nodes
+func (cm *ClusterMetric) PodCount() int64 {
+	return cm.podCount.current
+}
+
+// AllocatablePods returns the number of pods that can be scheduled to the
+// cluster's nodes
+func (cm *ClusterMetric) AllocatablePods() int64 {
+	return cm.podCount.allocatable
+}
+
+// Name returns the name of the node
+func (nm *NodeMetric) Name() string {
+	return nm.name
+}
+
+// Labels returns a copy of the labels of the node
+func (nm *NodeMetric) Labels() map[string]string {
+	labels := make(map[string]string, len(nm.labels))
+	for key, value := range nm.labels {
+		labels[key] = value
+	}
+	return labels
+}
+
+// Resource returns the totals of a resource for the node, or nil if the
+// resource was not collected
+func (nm *NodeMetric) Resource(name corev1.ResourceName) *ResourceMetric {
+	return nm.resources[name]
+}
+
+// Pods returns each pod on the node, sorted by name
+func (nm *NodeMetric) Pods() []*PodMetric {
+	return nm.getSortedPodMetrics("name")
+}
+
+// PodCount returns the number of pods scheduled to the node
+func (nm *NodeMetric) PodCount() int64 {
+	return nm.podCount.current
+}
+
+// AllocatablePods returns the number of pods that can be scheduled to the
+// node
+func (nm *NodeMetric) AllocatablePods() int64 {
+	return nm.podCount.allocatable
+}
+
+// Name returns the name of the pod
+func (pm *PodMetric) Name() string {
+	return pm.name
+}
+
+// Namespace returns the namespace of the pod
+func (pm *PodMetric) Namespace() string {
+	return pm.namespace
+}
+
+// PendingReason returns the reason the pod has not been scheduled, if any
+func (pm *PodMetric) PendingReason() string {
+	return pm.pendingReason
+}
+
+// Workload returns the kind and name of the controller that owns the pod,
+// or "Pod" and the name of the pod if it has none. Deployments and CronJobs
+// are only resolved when grouping by workload.
+func (pm *PodMetric) Workload() (kind, name string) {
+	return pm.workload.kind, pm.workload.name
+}
+
+// QOSClass returns the QoS class of the pod, e.g. "Burstable", or an empty
+// string if it has not been set
+func (pm *PodMetric) QOSClass() string {
+	return pm.qosClass
+}
+
+// PriorityClass returns the name of the priority class of the pod, if any
+func (pm *PodMetric) PriorityClass() string {
+	return pm.priorityClass
+}
+
+// Resource returns the requests, limits and utilization of a resource for
+// the pod, or nil if the resource was not collected
+func (pm *PodMetric) Resource(name corev1.ResourceName) *ResourceMetric {
+	return pm.resources[name]
+}
+
+// Containers returns each container of the pod, including init and
+// ephemeral containers, sorted by type and name
+func (pm *PodMetric) Containers() []*ContainerMetric {
+	return pm.getSortedContainerMetrics("name")
+}
+
+// Name returns the name of the container
+func (cm *ContainerMetric) Name() string {
+	return cm.name
+}
+
+// Type returns the type of the container: AppContainerType,
+// InitContainerType, SidecarContainerType, EphemeralContainerType, or
+// OverheadContainerType for the line holding the pod overhead
+func (cm *ContainerMetric) Type() string {
+	return cm.containerType
+}
+
+// Resource returns the requests, limits and utilization of a resource for
+// the container, or nil if the resource was not collected
+func (cm *ContainerMetric) Resource(name corev1.ResourceName) *ResourceMetric {
+	return cm.resources[name]
+}
+
+// Allocatable returns the amount of the resource that can be allocated.
+// Pods and containers carry the allocatable of the node they run on, so
+// their percentages are a share of that node. It is zero for unscheduled
+// pods and their containers, as they have no node yet.
+func (rm *ResourceMetric) Allocatable() resource.Quantity {
+	return rm.allocatable.DeepCopy()
+}
+
+// Utilization returns the amount of the resource in use, which is only
+// collected when utilization has been requested
+func (rm *ResourceMetric) Utilization() resource.Quantity {
+	return rm.utilization.DeepCopy()
+}
+
+// UtilizationStat returns the average, maximum or 95th percentile of the
+// utilization samples taken when Samples is greater than one, or the
+// utilization if it was only sampled once
+func (rm *ResourceMetric) UtilizationStat(stat string) resource.Quantity {
+	return rm.utilizationStat(stat).DeepCopy()
+}
+
+// Request returns the amount of the resource requested
+func (rm *ResourceMetric) Request() resource.Quantity {
+	return rm.request.DeepCopy()
+}
+
+// Limit returns the limit on the resource
+func (rm *ResourceMetric) Limit() resource.Quantity {
+	return rm.limit.DeepCopy()
+}
+
+func sortedResourceNames(resources map[corev1.ResourceName]*ResourceMetric) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
@@ -0,0 +1,338 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// auditResourceNames are the resources that every container is expected to
+// have requests and limits for.
+var auditResourceNames = []corev1.ResourceName{
+	corev1.ResourceCPU,
+	corev1.ResourceMemory,
+}
+
+// SupportedAuditOutputs returns a string list of output formats supported
+// by the audit command
+func SupportedAuditOutputs() []string {
+	return []string{
+		TableOutput,
+		JSONOutput,
+		YAMLOutput,
+	}
+}
+
+// auditFinding is a container of a workload that is missing requests or
+// limits, along with the number of pods it was found in and its total
+// utilization across them.
+type auditFinding struct {
+	namespace     string
+	workload      workloadRef
+	container     string
+	containerType string
+	missing       []string
+	pods          int
+	utilization   map[corev1.ResourceName]*resource.Quantity
+}
+
+// unaccountedUtilization is the utilization of a resource by containers
+// that do not request it, along with the utilization of every container.
+type unaccountedUtilization struct {
+	resourceName corev1.ResourceName
+	unaccounted  resource.Quantity
+	total        resource.Quantity
+}
+
+type listAudit struct {
+	Containers  []*listAuditContainer      `json:"containers"`
+	Unaccounted []*listUnaccountedResource `json:"unaccountedUtilization,omitempty"`
+}
+
+type listAuditContainer struct {
+	Namespace   string            `json:"namespace"`
+	Workload    string            `json:"workload"`
+	Container   string            `json:"container"`
+	Type        string            `json:"type"`
+	Pods        int               `json:"pods"`
+	Missing     []string          `json:"missing"`
+	Utilization map[string]string `json:"utilization,omitempty"`
+}
+
+type listUnaccountedResource struct {
+	Resource    string `json:"resource"`
+	Utilization string `json:"utilization"`
+	Total       string `json:"total"`
+	Percent     string `json:"percent"`
+}
+
+// FetchAndAudit gathers cluster resource data and outputs the containers,
+// including init and ephemeral containers, that are missing CPU or memory
+// requests or limits, grouped by namespace and owning workload. When
+// utilization has been requested, the utilization of containers without
+// requests is summarized.
+func FetchAndAudit(ctx context.Context, opts Options) error {
+	opts.Resources = []string{}
+	for _, name := range auditResourceNames {
+		opts.Resources = append(opts.Resources, string(name))
+	}
+
+	cm, err := fetchClusterMetric(ctx, opts, true)
+	if err != nil {
+		return err
+	}
+
+	findings, unaccounted := buildAudit(&cm)
+	if !opts.ShowUtil {
+		unaccounted = nil
+	}
+	return printAudit(findings, unaccounted, opts)
+}
+
+// buildAudit returns the containers of cm that are missing requests or
+// limits, along with the utilization of each audited resource by
+// containers that do not request it.
+func buildAudit(cm *ClusterMetric) ([]*auditFinding, []*unaccountedUtilization) {
+	findings := map[string]*auditFinding{}
+	unaccounted := make([]*unaccountedUtilization, len(auditResourceNames))
+	for i, name := range auditResourceNames {
+		unaccounted[i] = &unaccountedUtilization{resourceName: name}
+	}
+
+	audit := func(pm *PodMetric, ctm *ContainerMetric) {
+		missing := []string{}
+		for i, name := range auditResourceNames {
+			rm := ctm.resources[name]
+			if rm == nil {
+				continue
+			}
+			if rm.request.IsZero() {
+				missing = append(missing, string(name)+" request")
+				unaccounted[i].unaccounted.Add(rm.utilization)
+			}
+			if rm.limit.IsZero() {
+				missing = append(missing, string(name)+" limit")
+			}
+			unaccounted[i].total.Add(rm.utilization)
+		}
+		if len(missing) == 0 {
+			return
+		}
+
+		key := strings.Join([]string{pm.namespace, pm.workload.String(), ctm.containerType, ctm.name, strings.Join(missing, ",")}, "/")
+		f := findings[key]
+		if f == nil {
+			f = &auditFinding{
+				namespace:     pm.namespace,
+				workload:      pm.workload,
+				container:     ctm.name,
+				containerType: ctm.containerType,
+				missing:       missing,
+				utilization:   map[corev1.ResourceName]*resource.Quantity{},
+			}
+			for _, name := range auditResourceNames {
+				f.utilization[name] = &resource.Quantity{}
+			}
+			findings[key] = f
+		}
+		f.pods++
+		for name, q := range f.utilization {
+			if rm := ctm.resources[name]; rm != nil {
+				q.Add(rm.utilization)
+			}
+		}
+	}
+
+	nodeMetrics := []*NodeMetric{}
+	for _, nm := range cm.nodeMetrics {
+		nodeMetrics = append(nodeMetrics, nm)
+	}
+	if cm.unscheduled != nil {
+		nodeMetrics = append(nodeMetrics, cm.unscheduled)
+	}
+	for _, nm := range nodeMetrics {
+		for _, pm := range nm.podMetrics {
+			for _, ctm := range pm.containerMetrics {
+				if ctm.containerType != OverheadContainerType {
+					audit(pm, ctm)
+				}
+			}
+		}
+	}
+
+	return sortAuditFindings(findings), unaccounted
+}
+
+// sortAuditFindings returns findings ordered by namespace, workload, the
+// order their containers run in, and container name.
+func sortAuditFindings(findings map[string]*auditFinding) []*auditFinding {
+	sorted := make([]*auditFinding, 0, len(findings))
+	for _, f := range findings {
+		sorted = append(sorted, f)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		f1, f2 := sorted[i], sorted[j]
+		switch {
+		case f1.namespace != f2.namespace:
+			return f1.namespace < f2.namespace
+		case f1.workload != f2.workload:
+			return f1.workload.String() < f2.workload.String()
+		case f1.containerType != f2.containerType:
+			return containerTypeOrder[f1.containerType] < containerTypeOrder[f2.containerType]
+		case f1.container != f2.container:
+			return f1.container < f2.container
+		default:
+			return strings.Join(f1.missing, ",") < strings.Join(f2.missing, ",")
+		}
+	})
+	return sorted
+}
+
+func printAudit(findings []*auditFinding, unaccounted []*unaccountedUtilization, opts Options) error {
+	switch opts.OutputFormat {
+	case TableOutput:
+		printAuditTable(findings, unaccounted, opts)
+	case JSONOutput, YAMLOutput:
+		return printAuditList(buildListAudit(findings, unaccounted, opts), opts.OutputFormat)
+	default:
+		return fmt.Errorf("unsupported output type: %s", opts.OutputFormat)
+	}
+	return nil
+}
+
+func printAuditTable(findings []*auditFinding, unaccounted []*unaccountedUtilization, opts Options) {
+	if len(findings) == 0 {
+		fmt.Println("Every container has CPU and memory requests and limits")
+	} else {
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 0, 8, 2, ' ', 0)
+
+		printLine := func(namespace string, items ...string) {
+			if opts.Namespace == "" {
+				items = append([]string{namespace}, items...)
+			}
+			_, _ = fmt.Fprintln(w, strings.Join(items, "\t "))
+		}
+
+		header := []string{"WORKLOAD", "CONTAINER", "TYPE", "PODS", "MISSING"}
+		if opts.ShowUtil {
+			for _, name := range auditResourceNames {
+				header = append(header, resourceDisplayName(name)+" UTIL")
+			}
+		}
+		printLine("NAMESPACE", header...)
+
+		for _, f := range findings {
+			items := []string{f.workload.String(), f.container, f.containerType, fmt.Sprintf("%d", f.pods), strings.Join(f.missing, ", ")}
+			if opts.ShowUtil {
+				for _, name := range auditResourceNames {
+					items = append(items, recommendationString(name, *f.utilization[name]))
+				}
+			}
+			printLine(f.namespace, items...)
+		}
+
+		err := w.Flush()
+		if err != nil {
+			fmt.Printf("Error writing to table: %s", err)
+		}
+	}
+
+	if len(unaccounted) > 0 {
+		fmt.Println()
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 0, 8, 2, ' ', 0)
+
+		_, _ = fmt.Fprintln(w, strings.Join([]string{"RESOURCE", "UTIL WITHOUT REQUESTS", "TOTAL UTIL", "UNACCOUNTED"}, "\t "))
+		for _, u := range unaccounted {
+			_, _ = fmt.Fprintln(w, strings.Join([]string{
+				resourceDisplayName(u.resourceName),
+				recommendationString(u.resourceName, u.unaccounted),
+				recommendationString(u.resourceName, u.total),
+				u.percentString(),
+			}, "\t "))
+		}
+
+		err := w.Flush()
+		if err != nil {
+			fmt.Printf("Error writing to table: %s", err)
+		}
+	}
+}
+
+// percentString returns the share of utilization that is unaccounted for.
+func (u *unaccountedUtilization) percentString() string {
+	return resourceCSVPercentageString(u.unaccounted, u.total) + "%"
+}
+
+func buildListAudit(findings []*auditFinding, unaccounted []*unaccountedUtilization, opts Options) *listAudit {
+	response := &listAudit{Containers: []*listAuditContainer{}}
+	for _, f := range findings {
+		c := &listAuditContainer{
+			Namespace: f.namespace,
+			Workload:  f.workload.String(),
+			Container: f.container,
+			Type:      f.containerType,
+			Pods:      f.pods,
+			Missing:   f.missing,
+		}
+		if opts.ShowUtil {
+			c.Utilization = map[string]string{}
+			for _, name := range auditResourceNames {
+				c.Utilization[string(name)] = recommendationString(name, *f.utilization[name])
+			}
+		}
+		response.Containers = append(response.Containers, c)
+	}
+
+	for _, u := range unaccounted {
+		response.Unaccounted = append(response.Unaccounted, &listUnaccountedResource{
+			Resource:    string(u.resourceName),
+			Utilization: recommendationString(u.resourceName, u.unaccounted),
+			Total:       recommendationString(u.resourceName, u.total),
+			Percent:     u.percentString(),
+		})
+	}
+	return response
+}
+
+func printAuditList(response *listAudit, outputType string) error {
+	jsonRaw, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling JSON: %w", err)
+	}
+
+	if outputType == JSONOutput {
+		fmt.Printf("%s\n", jsonRaw)
+		return nil
+	}
+
+	yamlRaw, err := yaml.JSONToYAML(jsonRaw)
+	if err != nil {
+		return fmt.Errorf("converting JSON to YAML: %w", err)
+	}
+	fmt.Printf("%s", yamlRaw)
+	return nil
+}
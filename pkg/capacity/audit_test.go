@@ -0,0 +1,122 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+func TestBuildAudit(t *testing.T) {
+	controller := true
+	limited := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{"cpu": resource.MustParse("100m"), "memory": resource.MustParse("64Mi")},
+		Limits:   corev1.ResourceList{"cpu": resource.MustParse("200m"), "memory": resource.MustParse("128Mi")},
+	}
+	auditPod := func(name string) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       "default",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-5d8", Controller: &controller}},
+			},
+			Spec: corev1.PodSpec{
+				NodeName:       "node-1",
+				InitContainers: []corev1.Container{{Name: "migrate"}},
+				Containers: []corev1.Container{
+					{Name: "app", Resources: limited},
+					{Name: "proxy", Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{"memory": resource.MustParse("32Mi")},
+					}},
+				},
+			},
+		}
+	}
+
+	podList := &corev1.PodList{Items: []corev1.Pod{auditPod("web-1"), auditPod("web-2")}}
+	podList.Items[0].Spec.EphemeralContainers = []corev1.EphemeralContainer{{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debugger"},
+	}}
+
+	usage := func(cpu, memory string) corev1.ResourceList {
+		return corev1.ResourceList{"cpu": resource.MustParse(cpu), "memory": resource.MustParse(memory)}
+	}
+	pmList := &v1beta1.PodMetricsList{Items: []v1beta1.PodMetrics{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+			Containers: []v1beta1.ContainerMetrics{
+				{Name: "app", Usage: usage("50m", "60Mi")},
+				{Name: "proxy", Usage: usage("30m", "20Mi")},
+				{Name: "debugger", Usage: usage("5m", "4Mi")},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-2", Namespace: "default"},
+			Containers: []v1beta1.ContainerMetrics{
+				{Name: "app", Usage: usage("50m", "60Mi")},
+				{Name: "proxy", Usage: usage("20m", "20Mi")},
+			},
+		},
+	}}
+
+	cm := buildClusterMetric(podList, pmList, getGroupTestNodeList(), nil, defaultResourceNames)
+
+	pm := cm.nodeMetrics["node-1"].podMetrics["default-web-1"]
+	assert.Equal(t, EphemeralContainerType, pm.containerMetrics["debugger"].containerType)
+	assert.Equal(t, "5m", quantityString(pm.containerMetrics["debugger"].resources["cpu"].utilization))
+	assert.Equal(t, InitContainerType, pm.containerMetrics["migrate"].containerType)
+
+	findings, unaccounted := buildAudit(&cm)
+	rows := [][]string{}
+	for _, f := range findings {
+		rows = append(rows, []string{f.namespace, f.workload.String(), f.container, f.containerType, quantityString(*f.utilization["cpu"])})
+		assert.Equal(t, 2-boolInt(f.containerType == EphemeralContainerType), f.pods)
+	}
+	assert.Equal(t, [][]string{
+		{"default", "ReplicaSet/web-5d8", "migrate", InitContainerType, "0"},
+		{"default", "ReplicaSet/web-5d8", "proxy", AppContainerType, "50m"},
+		{"default", "ReplicaSet/web-5d8", "debugger", EphemeralContainerType, "5m"},
+	}, rows)
+	assert.Equal(t, []string{"cpu request", "cpu limit", "memory limit"}, findings[1].missing)
+
+	assert.Len(t, unaccounted, 2)
+	assert.Equal(t, corev1.ResourceCPU, unaccounted[0].resourceName)
+	assert.Equal(t, "55m", quantityString(unaccounted[0].unaccounted))
+	assert.Equal(t, "155m", quantityString(unaccounted[0].total))
+	assert.Equal(t, "35%", unaccounted[0].percentString())
+	assert.Equal(t, "4Mi", quantityString(unaccounted[1].unaccounted))
+
+	list := buildListAudit(findings, unaccounted, Options{ShowUtil: true})
+	assert.Len(t, list.Containers, 3)
+	assert.Equal(t, "ReplicaSet/web-5d8", list.Containers[1].Workload)
+	assert.Equal(t, map[string]string{"cpu": "50m", "memory": "40Mi"}, list.Containers[1].Utilization)
+	assert.Equal(t, &listUnaccountedResource{Resource: "memory", Utilization: "4Mi", Total: "164Mi", Percent: "2%"}, list.Unaccounted[1])
+
+	list = buildListAudit(findings, nil, Options{})
+	assert.Nil(t, list.Containers[0].Utilization)
+	assert.Empty(t, list.Unaccounted)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
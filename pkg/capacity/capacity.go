@@ -15,73 +15,295 @@
 package capacity
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/robscott/kube-capacity/pkg/kube"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
 	k8taints "k8s.io/kubernetes/pkg/util/taints"
 	v1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	metrics "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
-// FetchAndPrint gathers cluster resource data and outputs it
-func FetchAndPrint(opts Options) {
-	clientset, err := kube.NewClientSet(opts.KubeContext, opts.KubeConfig, opts.InsecureSkipTLSVerify, opts.ImpersonateUser, opts.ImpersonateGroup)
+// Collect gathers cluster resource data with existing clients. A metrics
+// client is only required when utilization has been requested with
+// ShowUtil from the Metrics API, and the workload that owns each pod is
+// only resolved when grouping by workload.
+func Collect(ctx context.Context, clientset kubernetes.Interface, metricsClient metrics.Interface, opts Options) (*ClusterMetric, error) {
+	if opts.usesMetricsServer() && metricsClient == nil {
+		return nil, &ConnectionError{API: MetricsAPI, Err: errors.New("no client provided")}
+	}
+
+	cm, err := collectClusterMetric(ctx, clientset, metricsClient, opts, opts.GroupBy == WorkloadGroup)
 	if err != nil {
-		fmt.Printf("Error connecting to Kubernetes: %v\n", err)
-		os.Exit(1)
+		return nil, err
+	}
+	return &cm, nil
+}
+
+// FetchAndPrint gathers cluster resource data and outputs it. Requests are
+// cancelled when ctx is.
+func FetchAndPrint(ctx context.Context, opts Options) error {
+	if opts.multiCluster() {
+		return fetchAndPrintContexts(ctx, opts)
 	}
 
-	podList, nodeList := getPodsAndNodes(clientset, opts.ExcludeTainted, opts.PodLabels, opts.NodeLabels, opts.NodeTaints, opts.NamespaceLabels, opts.Namespace)
-	var pmList *v1beta1.PodMetricsList
-	var nmList *v1beta1.NodeMetricsList
+	clientset, mClientset, err := newClientSets(opts)
+	if err != nil {
+		return err
+	}
+	if opts.Watch {
+		return watchAndPrint(ctx, clientset, mClientset, opts)
+	}
+
+	cm, err := collectClusterMetric(ctx, clientset, mClientset, opts, opts.GroupBy == WorkloadGroup)
+	if err != nil {
+		return err
+	}
+	return printList(&cm, opts)
+}
+
+// fetchClusterMetric gathers cluster resource data, optionally resolving
+// the workload that owns each pod.
+func fetchClusterMetric(ctx context.Context, opts Options, resolveWorkloads bool) (ClusterMetric, error) {
+	clientset, mClientset, err := newClientSets(opts)
+	if err != nil {
+		return ClusterMetric{}, err
+	}
+	return collectClusterMetric(ctx, clientset, mClientset, opts, resolveWorkloads)
+}
 
-	if opts.ShowUtil {
-		mClientset, err := kube.NewMetricsClientSet(opts.KubeContext, opts.KubeConfig, opts.InsecureSkipTLSVerify)
+// newClientSets connects to the Kubernetes API, and to the Metrics API if
+// utilization has been requested from it, or reads objects from files if they have
+// been specified.
+func newClientSets(opts Options) (kubernetes.Interface, metrics.Interface, error) {
+	if len(opts.FromFiles) > 0 {
+		clientset, mClientset, err := newFileClientSets(opts.FromFiles)
 		if err != nil {
-			fmt.Printf("Error connecting to Metrics API: %v\n", err)
-			os.Exit(4)
+			return nil, nil, fmt.Errorf("reading files: %w", err)
 		}
+		return clientset, mClientset, nil
+	}
 
-		pmList = getPodMetrics(mClientset, opts.Namespace)
-		if opts.Namespace == "" && opts.NamespaceLabels == "" {
-			nmList = getNodeMetrics(mClientset, nodeList, opts.NodeLabels)
-		}
+	clientset, err := kube.NewClientSet(opts.KubeContext, opts.KubeConfig, opts.InsecureSkipTLSVerify, opts.ImpersonateUser, opts.ImpersonateGroup, opts.RequestTimeout)
+	if err != nil {
+		return nil, nil, &ConnectionError{API: KubernetesAPI, Err: err}
 	}
 
-	cm := buildClusterMetric(podList, pmList, nodeList, nmList)
-	printList(&cm, opts)
+	if !opts.usesMetricsServer() {
+		return clientset, nil, nil
+	}
+
+	mClientset, err := kube.NewMetricsClientSet(opts.KubeContext, opts.KubeConfig, opts.InsecureSkipTLSVerify, opts.RequestTimeout)
+	if err != nil {
+		return nil, nil, &ConnectionError{API: MetricsAPI, Err: err}
+	}
+
+	return clientset, mClientset, nil
 }
 
-func getPodsAndNodes(clientset kubernetes.Interface, excludeTainted bool, podLabels, nodeLabels, nodeTaints, namespaceLabels, namespace string) (*corev1.PodList, *corev1.NodeList) {
-	nodeList, err := clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{
-		LabelSelector: nodeLabels,
+// maxNodePodLists is the largest number of nodes selected by labels for
+// which pods are listed separately for each node, rather than listing every
+// pod in the cluster and discarding those on other nodes.
+const maxNodePodLists = 20
+
+// collectClusterMetric lists nodes, namespaces, metrics if utilization has
+// been requested, and workload owners concurrently with existing clients,
+// followed by pods, which are added to a ClusterMetric a page at a time as
+// they are listed. When utilization is read from the kubelet, each node's
+// Summary API is queried once nodes have been listed. When utilization is
+// sampled, metrics are fetched again every SampleInterval until Samples
+// have been taken.
+func collectClusterMetric(ctx context.Context, clientset kubernetes.Interface, mClientset metrics.Interface, opts Options, resolveWorkloads bool) (ClusterMetric, error) {
+	start := time.Now()
+	g := newFetchGroup(ctx)
+	defer g.cancel()
+
+	var nodeList *corev1.NodeList
+	g.Go("nodes", func(ctx context.Context) error {
+		var err error
+		nodeList, err = getNodes(ctx, clientset, opts.ChunkSize, opts.ExcludeTainted, opts.NodeLabels, opts.NodeTaints)
+		return err
 	})
-	if err != nil {
-		fmt.Printf("Error listing Nodes: %v\n", err)
-		os.Exit(2)
+
+	var namespaces map[string]bool
+	if opts.Namespace == "" && opts.NamespaceLabels != "" {
+		g.Go("namespaces", func(ctx context.Context) error {
+			var err error
+			namespaces, err = getNamespaces(ctx, clientset, opts.ChunkSize, opts.NamespaceLabels)
+			return err
+		})
 	}
-	if excludeTainted {
-		filteredNodeList := []corev1.Node{}
-		for _, node := range nodeList.Items {
-			if len(node.Spec.Taints) == 0 {
-				filteredNodeList = append(filteredNodeList, node)
+
+	// Each sample is fetched in turn, with the first fetched alongside
+	// nodes. Utilization from the kubelet can only be fetched once nodes
+	// have been listed.
+	samples := make([]*metricsSample, max(opts.Samples, 1))
+	for i := range samples {
+		samples[i] = &metricsSample{}
+	}
+	includeNodes := opts.Namespace == "" && opts.NamespaceLabels == ""
+	fetchMetrics := func(s *metricsSample, suffix string, nodeList *corev1.NodeList) {
+		if opts.usesMetricsServer() {
+			g.Go("pod metrics"+suffix, func(ctx context.Context) error {
+				var err error
+				s.pmList, err = getPodMetrics(ctx, mClientset, opts.ChunkSize, opts.Namespace)
+				return err
+			})
+			if includeNodes {
+				g.Go("node metrics"+suffix, func(ctx context.Context) error {
+					var err error
+					s.nmList, err = getNodeMetrics(ctx, mClientset, opts.ChunkSize, opts.NodeLabels)
+					return err
+				})
 			}
 		}
-		nodeList.Items = filteredNodeList
+
+		if opts.ShowUtil && opts.MetricsSource == PrometheusSource {
+			g.Go("prometheus"+suffix, func(ctx context.Context) error {
+				var err error
+				s.pmList, s.nmList, err = getPrometheusMetrics(ctx, opts, includeNodes)
+				return err
+			})
+		}
+
+		if opts.ShowUtil && opts.MetricsSource == KubeletSource && nodeList != nil {
+			g.Go("kubelet summaries"+suffix, func(ctx context.Context) error {
+				var err error
+				s.pmList, s.nmList, err = getKubeletMetrics(ctx, clientset, nodeList, includeNodes)
+				return err
+			})
+		}
+	}
+	fetchMetrics(samples[0], "", nil)
+
+	var owners map[string]workloadRef
+	if resolveWorkloads {
+		g.Go("workload owners", func(ctx context.Context) error {
+			var err error
+			owners, err = getWorkloadOwners(ctx, clientset, opts.ChunkSize, opts.Namespace)
+			return err
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return ClusterMetric{}, err
+	}
+
+	if opts.ShowUtil && opts.MetricsSource == KubeletSource {
+		fetchMetrics(samples[0], "", nodeList)
+		if err := g.Wait(); err != nil {
+			return ClusterMetric{}, err
+		}
+	}
+
+	for i := 1; i < len(samples); i++ {
+		select {
+		case <-ctx.Done():
+			return ClusterMetric{}, ctx.Err()
+		case <-time.After(opts.SampleInterval):
+		}
+
+		fetchMetrics(samples[i], fmt.Sprintf(" (sample %d)", i+1), nodeList)
+		if err := g.Wait(); err != nil {
+			return ClusterMetric{}, err
+		}
+	}
+
+	// Pods are added as they are listed, so listing them has to wait for the
+	// nodes and metrics they are added to. When sampling, pods are added to
+	// a ClusterMetric for each sample, with the last reported.
+	cms := make([]ClusterMetric, len(samples))
+	podMetrics := make([]map[string]v1beta1.PodMetrics, len(samples))
+	for i, s := range samples {
+		cms[i] = newClusterMetric(nodeList, s.nmList, opts.resourceNames())
+		podMetrics[i] = podMetricsByKey(s.pmList)
+	}
+	perNode := opts.NodeLabels != "" && len(nodeList.Items) <= maxNodePodLists
+	g.Go("pods", func(ctx context.Context) error {
+		return eachPod(ctx, clientset, opts.ChunkSize, nodeList, namespaces, opts.PodLabels, opts.FieldSelector, opts.Namespace, opts.ShowPending, perNode, func(pod *corev1.Pod) {
+			for i := range cms {
+				cms[i].addPod(pod, podMetrics[i])
+			}
+		})
+	})
+	if err := g.Wait(); err != nil {
+		return ClusterMetric{}, err
+	}
+	for i, s := range samples {
+		cms[i].addNodeTotals(s.nmList == nil)
+	}
+
+	cm := cms[len(cms)-1]
+	if len(cms) > 1 {
+		cm.setSamples(cms)
+	}
+
+	if resolveWorkloads {
+		cm.resolveWorkloads(owners)
+	}
+
+	if opts.Verbose {
+		title := fmt.Sprintf("Fetched data in %s:", time.Since(start).Round(time.Millisecond))
+		if opts.KubeContext != "" {
+			title = fmt.Sprintf("Fetched data from context %s in %s:", opts.KubeContext, time.Since(start).Round(time.Millisecond))
+		}
+		var buf bytes.Buffer
+		g.printTimings(&buf, title, time.Since(start))
+		_, _ = os.Stderr.Write(buf.Bytes())
+	}
+	return cm, nil
+}
+
+// listPages calls list with Limit and Continue set until every page of a
+// list has been retrieved, passing each page to fn as it arrives. A chunk
+// size of 0 retrieves the list with a single request.
+func listPages[T interface{ GetContinue() string }](ctx context.Context, chunkSize int64, listOpts metav1.ListOptions, list func(context.Context, metav1.ListOptions) (T, error), fn func(T)) error {
+	listOpts.Limit = chunkSize
+	for {
+		page, err := list(ctx, listOpts)
+		if err != nil {
+			return err
+		}
+		fn(page)
+
+		listOpts.Continue = page.GetContinue()
+		if listOpts.Continue == "" {
+			return nil
+		}
+	}
+}
+
+func getNodes(ctx context.Context, clientset kubernetes.Interface, chunkSize int64, excludeTainted bool, nodeLabels, nodeTaints string) (*corev1.NodeList, error) {
+	nodeList := &corev1.NodeList{}
+	err := listPages(ctx, chunkSize, metav1.ListOptions{LabelSelector: nodeLabels}, clientset.CoreV1().Nodes().List, func(page *corev1.NodeList) {
+		for _, node := range page.Items {
+			if excludeTainted && len(node.Spec.Taints) > 0 {
+				continue
+			}
+			nodeList.Items = append(nodeList.Items, node)
+		}
+	})
+	if err != nil {
+		return nil, &ListError{Resource: "Nodes", Err: err}
 	}
 
 	if nodeTaints != "" {
 		taints := strings.Split(nodeTaints, ",")
 		taintsToAdd, taintsToRemove, error := k8taints.ParseTaints(taints)
 		if error != nil {
-			fmt.Printf("Error parsing taint parameter: %v\n", error)
-			os.Exit(3)
+			return nil, &OptionError{Option: "taint", Err: error}
 		}
 
 		var tempAddNodeList corev1.NodeList
@@ -136,82 +358,171 @@ func getPodsAndNodes(clientset kubernetes.Interface, excludeTainted bool, podLab
 		}
 	}
 
-	podList, err := clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
-		LabelSelector: podLabels,
+	return nodeList, nil
+}
+
+// getNamespaces returns the names of the namespaces matching the namespace
+// labels.
+func getNamespaces(ctx context.Context, clientset kubernetes.Interface, chunkSize int64, namespaceLabels string) (map[string]bool, error) {
+	namespaces := map[string]bool{}
+	err := listPages(ctx, chunkSize, metav1.ListOptions{LabelSelector: namespaceLabels}, clientset.CoreV1().Namespaces().List, func(page *corev1.NamespaceList) {
+		for _, ns := range page.Items {
+			namespaces[ns.GetName()] = true
+		}
 	})
 	if err != nil {
-		fmt.Printf("Error listing Pods: %v\n", err)
-		os.Exit(3)
+		return nil, &ListError{Resource: "Namespaces", Err: err}
 	}
+	return namespaces, nil
+}
 
-	newPodItems := []corev1.Pod{}
+// eachPod lists pods a page at a time, calling fn with each pod that has
+// been scheduled to one of the given nodes, or that is pending if pending
+// pods have been requested, and that belongs to one of the given namespaces
+// if there are any. Pods that have succeeded or failed are filtered out by
+// the API server, along with those not matching the field selector. When
+// perNode is set, the pods on each node are listed concurrently with a
+// spec.nodeName field selector. fn is never called concurrently.
+func eachPod(ctx context.Context, clientset kubernetes.Interface, chunkSize int64, nodeList *corev1.NodeList, namespaces map[string]bool, podLabels, fieldSelector, namespace string, includePending, perNode bool, fn func(pod *corev1.Pod)) error {
+	selector, err := podFieldSelector(fieldSelector)
+	if err != nil {
+		return &OptionError{Option: "field selector", Err: err}
+	}
 
-	nodes := map[string]bool{}
+	nodeNames := make([]string, 0, len(nodeList.Items)+1)
 	for _, node := range nodeList.Items {
-		nodes[node.GetName()] = true
+		nodeNames = append(nodeNames, node.GetName())
 	}
-
-	for _, pod := range podList.Items {
-		if !nodes[pod.Spec.NodeName] {
-			continue
-		}
-
-		newPodItems = append(newPodItems, pod)
+	// Pods that haven't been scheduled to a node have an empty node name, and
+	// are only included when pending pods have been requested.
+	if includePending {
+		nodeNames = append(nodeNames, "")
 	}
 
-	podList.Items = newPodItems
+	// addPods returns a function passing the pods in a page that have been
+	// scheduled to one of the given nodes to fn.
+	var mu sync.Mutex
+	addPods := func(nodeNames ...string) func(page *corev1.PodList) {
+		nodes := map[string]bool{}
+		for _, nodeName := range nodeNames {
+			nodes[nodeName] = true
+		}
+		return func(page *corev1.PodList) {
+			mu.Lock()
+			defer mu.Unlock()
+			for i := range page.Items {
+				pod := &page.Items[i]
+				if !nodes[pod.Spec.NodeName] {
+					continue
+				}
+				if namespaces != nil && !namespaces[pod.GetNamespace()] {
+					continue
+				}
 
-	if namespace == "" && namespaceLabels != "" {
-		namespaceList, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{
-			LabelSelector: namespaceLabels,
-		})
-		if err != nil {
-			fmt.Printf("Error listing Namespaces: %v\n", err)
-			os.Exit(3)
+				fn(pod)
+			}
 		}
+	}
 
-		namespaces := map[string]bool{}
-		for _, ns := range namespaceList.Items {
-			namespaces[ns.GetName()] = true
+	if !perNode {
+		listOpts := metav1.ListOptions{LabelSelector: podLabels, FieldSelector: selector.String()}
+		if err := listPages(ctx, chunkSize, listOpts, clientset.CoreV1().Pods(namespace).List, addPods(nodeNames...)); err != nil {
+			return &ListError{Resource: "Pods", Err: err}
 		}
+		return nil
+	}
 
-		newPodItems := []corev1.Pod{}
+	errs := make([]error, len(nodeNames))
+	var wg sync.WaitGroup
+	for i, nodeName := range nodeNames {
+		wg.Add(1)
+		go func(i int, nodeName string) {
+			defer wg.Done()
 
-		for _, pod := range podList.Items {
-			if !namespaces[pod.GetNamespace()] {
-				continue
+			listOpts := metav1.ListOptions{
+				LabelSelector: podLabels,
+				FieldSelector: fields.AndSelectors(selector, fields.OneTermEqualSelector("spec.nodeName", nodeName)).String(),
 			}
+			errs[i] = listPages(ctx, chunkSize, listOpts, clientset.CoreV1().Pods(namespace).List, addPods(nodeName))
+		}(i, nodeName)
+	}
+	wg.Wait()
 
-			newPodItems = append(newPodItems, pod)
+	for _, err := range errs {
+		if err != nil {
+			return &ListError{Resource: "Pods", Err: err}
 		}
+	}
+	return nil
+}
 
-		podList.Items = newPodItems
+// podFieldSelector returns a field selector matching pods that have neither
+// succeeded nor failed, as well as the given field selector if there is one.
+func podFieldSelector(fieldSelector string) (fields.Selector, error) {
+	selectors := []fields.Selector{
+		fields.OneTermNotEqualSelector("status.phase", string(corev1.PodSucceeded)),
+		fields.OneTermNotEqualSelector("status.phase", string(corev1.PodFailed)),
+	}
+	if fieldSelector != "" {
+		selector, err := fields.ParseSelector(fieldSelector)
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
 	}
+	return fields.AndSelectors(selectors...), nil
+}
 
-	return podList, nodeList
+func getPodMetrics(ctx context.Context, mClientset metrics.Interface, chunkSize int64, namespace string) (*v1beta1.PodMetricsList, error) {
+	pmList := &v1beta1.PodMetricsList{}
+	err := listPages(ctx, chunkSize, metav1.ListOptions{}, mClientset.MetricsV1beta1().PodMetricses(namespace).List, func(page *v1beta1.PodMetricsList) {
+		pmList.Items = append(pmList.Items, page.Items...)
+	})
+	if err != nil {
+		return nil, &MetricsError{Resource: "Pod", Err: err}
+	}
+
+	return pmList, nil
 }
 
-func getPodMetrics(mClientset *metrics.Clientset, namespace string) *v1beta1.PodMetricsList {
-	pmList, err := mClientset.MetricsV1beta1().PodMetricses(namespace).List(context.TODO(), metav1.ListOptions{})
+func getNodeMetrics(ctx context.Context, mClientset metrics.Interface, chunkSize int64, nodeLabels string) (*v1beta1.NodeMetricsList, error) {
+	nmList := &v1beta1.NodeMetricsList{}
+	err := listPages(ctx, chunkSize, metav1.ListOptions{LabelSelector: nodeLabels}, mClientset.MetricsV1beta1().NodeMetricses().List, func(page *v1beta1.NodeMetricsList) {
+		nmList.Items = append(nmList.Items, page.Items...)
+	})
 	if err != nil {
-		fmt.Printf("Error getting Pod Metrics: %v\n", err)
-		fmt.Println("For this to work, metrics-server needs to be running in your cluster")
-		os.Exit(6)
+		return nil, &MetricsError{Resource: "Node", Err: err}
 	}
 
-	return pmList
+	return nmList, nil
 }
 
-func getNodeMetrics(mClientset *metrics.Clientset, nodeList *corev1.NodeList, nodeLabels string) *v1beta1.NodeMetricsList {
-	nmList, err := mClientset.MetricsV1beta1().NodeMetricses().List(context.TODO(), metav1.ListOptions{
-		LabelSelector: nodeLabels,
+// getWorkloadOwners returns the controllers of the ReplicaSets and Jobs in a
+// namespace, keyed by workloadOwnerKey.
+func getWorkloadOwners(ctx context.Context, clientset kubernetes.Interface, chunkSize int64, namespace string) (map[string]workloadRef, error) {
+	owners := map[string]workloadRef{}
+
+	err := listPages(ctx, chunkSize, metav1.ListOptions{}, clientset.AppsV1().ReplicaSets(namespace).List, func(page *appsv1.ReplicaSetList) {
+		for _, rs := range page.Items {
+			if owner := metav1.GetControllerOf(&rs); owner != nil {
+				owners[workloadOwnerKey(rs.Namespace, workloadRef{kind: "ReplicaSet", name: rs.Name})] = workloadRef{kind: owner.Kind, name: owner.Name}
+			}
+		}
 	})
+	if err != nil {
+		return nil, &ListError{Resource: "ReplicaSets", Err: err}
+	}
 
+	err = listPages(ctx, chunkSize, metav1.ListOptions{}, clientset.BatchV1().Jobs(namespace).List, func(page *batchv1.JobList) {
+		for _, job := range page.Items {
+			if owner := metav1.GetControllerOf(&job); owner != nil {
+				owners[workloadOwnerKey(job.Namespace, workloadRef{kind: "Job", name: job.Name})] = workloadRef{kind: owner.Kind, name: owner.Name}
+			}
+		}
+	})
 	if err != nil {
-		fmt.Printf("Error getting Node Metrics: %v\n", err)
-		fmt.Println("For this to work, metrics-server needs to be running in your cluster")
-		os.Exit(7)
+		return nil, &ListError{Resource: "Jobs", Err: err}
 	}
 
-	return nmList
+	return owners, nil
 }
@@ -15,12 +15,21 @@
 package capacity
 
 import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestGetPodsAndNodes(t *testing.T) {
@@ -44,7 +53,8 @@ func TestGetPodsAndNodes(t *testing.T) {
 		pod("mynode4", "default", "mypod8", map[string]string{"g": "test"}),
 	)
 
-	podList, nodeList := getPodsAndNodes(clientset, false, "", "", "", "", "")
+	podList, nodeList, err := listPodsAndNodes(clientset, false, "", "", "", "", "", false)
+	assert.NoError(t, err)
 	assert.Equal(t, []string{"mynode", "mynode2", "mynode3", "mynode4"}, listNodes(nodeList))
 	assert.Equal(t, []string{
 		"another/mypod5",
@@ -58,7 +68,8 @@ func TestGetPodsAndNodes(t *testing.T) {
 		"other/mypod3",
 	}, listPods(podList))
 
-	podList, nodeList = getPodsAndNodes(clientset, true, "", "hello=world", "", "", "")
+	podList, nodeList, err = listPodsAndNodes(clientset, true, "", "hello=world", "", "", "", false)
+	assert.NoError(t, err)
 	assert.Equal(t, []string{"mynode"}, listNodes(nodeList))
 	assert.Equal(t, []string{
 		"another/mypod5",
@@ -67,7 +78,8 @@ func TestGetPodsAndNodes(t *testing.T) {
 		"other/mypod2",
 	}, listPods(podList))
 
-	podList, nodeList = getPodsAndNodes(clientset, false, "", "hello=world", "", "", "")
+	podList, nodeList, err = listPodsAndNodes(clientset, false, "", "hello=world", "", "", "", false)
+	assert.NoError(t, err)
 	assert.Equal(t, []string{"mynode", "mynode2", "mynode3"}, listNodes(nodeList))
 	assert.Equal(t, []string{
 		"another/mypod5",
@@ -80,7 +92,8 @@ func TestGetPodsAndNodes(t *testing.T) {
 		"other/mypod3",
 	}, listPods(podList))
 
-	podList, nodeList = getPodsAndNodes(clientset, false, "", "moon=lol", "", "", "")
+	podList, nodeList, err = listPodsAndNodes(clientset, false, "", "moon=lol", "", "", "", false)
+	assert.NoError(t, err)
 
 	assert.Equal(t, []string{"mynode2"}, listNodes(nodeList))
 	assert.Equal(t, []string{
@@ -89,26 +102,30 @@ func TestGetPodsAndNodes(t *testing.T) {
 		"other/mypod3",
 	}, listPods(podList))
 
-	podList, nodeList = getPodsAndNodes(clientset, false, "a=test", "", "", "", "")
+	podList, nodeList, err = listPodsAndNodes(clientset, false, "a=test", "", "", "", "", false)
+	assert.NoError(t, err)
 	assert.Equal(t, []string{"mynode", "mynode2", "mynode3", "mynode4"}, listNodes(nodeList))
 
 	assert.Equal(t, []string{
 		"default/mypod",
 	}, listPods(podList))
 
-	podList, nodeList = getPodsAndNodes(clientset, false, "a=test,b!=test", "", "", "app=true", "")
+	podList, nodeList, err = listPodsAndNodes(clientset, false, "a=test,b!=test", "", "", "app=true", "", false)
+	assert.NoError(t, err)
 	assert.Equal(t, []string{"mynode", "mynode2", "mynode3", "mynode4"}, listNodes(nodeList))
 	assert.Equal(t, []string{
 		"default/mypod",
 	}, listPods(podList))
 
-	podList, nodeList = getPodsAndNodes(clientset, false, "a=test,b!=test", "", "", "", "default")
+	podList, nodeList, err = listPodsAndNodes(clientset, false, "a=test,b!=test", "", "", "", "default", false)
+	assert.NoError(t, err)
 	assert.Equal(t, []string{"mynode", "mynode2", "mynode3", "mynode4"}, listNodes(nodeList))
 
 	assert.Equal(t, []string{
 		"default/mypod",
 	}, listPods(podList))
-	podList, nodeList = getPodsAndNodes(clientset, false, "", "", "taintkey=taintvalue:NoSchedule-", "", "")
+	podList, nodeList, err = listPodsAndNodes(clientset, false, "", "", "taintkey=taintvalue:NoSchedule-", "", "", false)
+	assert.NoError(t, err)
 	assert.Equal(t, []string{"mynode", "mynode2"}, listNodes(nodeList))
 	assert.Equal(t, []string{
 		"another/mypod5",
@@ -119,7 +136,8 @@ func TestGetPodsAndNodes(t *testing.T) {
 		"other/mypod2",
 		"other/mypod3",
 	}, listPods(podList))
-	podList, nodeList = getPodsAndNodes(clientset, false, "", "", "taintkey:NoSchedule-", "", "")
+	podList, nodeList, err = listPodsAndNodes(clientset, false, "", "", "taintkey:NoSchedule-", "", "", false)
+	assert.NoError(t, err)
 	assert.Equal(t, []string{"mynode", "mynode2"}, listNodes(nodeList))
 	assert.Equal(t, []string{
 		"another/mypod5",
@@ -130,7 +148,8 @@ func TestGetPodsAndNodes(t *testing.T) {
 		"other/mypod2",
 		"other/mypod3",
 	}, listPods(podList))
-	podList, nodeList = getPodsAndNodes(clientset, false, "", "", "taintkey=taintvalue:NoSchedule", "", "")
+	podList, nodeList, err = listPodsAndNodes(clientset, false, "", "", "taintkey=taintvalue:NoSchedule", "", "", false)
+	assert.NoError(t, err)
 	assert.Equal(t, []string{"mynode3", "mynode4"}, listNodes(nodeList))
 	assert.Equal(t, []string{
 		"default/mypod7",
@@ -138,6 +157,291 @@ func TestGetPodsAndNodes(t *testing.T) {
 	}, listPods(podList))
 }
 
+func TestGetPodsAndNodesPending(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		node("mynode", map[string]string{"hello": "world"}, false),
+		node("mynode2", map[string]string{}, false),
+		pod("mynode", "default", "mypod", map[string]string{"a": "test"}),
+		pod("mynode2", "default", "mypod2", map[string]string{"a": "test"}),
+		pod("", "default", "mypod3", map[string]string{"a": "test"}),
+		pod("", "other", "mypod4", map[string]string{"b": "test"}),
+	)
+
+	podList, _, err := listPodsAndNodes(clientset, false, "", "", "", "", "", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"default/mypod",
+		"default/mypod2",
+	}, listPods(podList))
+
+	podList, _, err = listPodsAndNodes(clientset, false, "", "", "", "", "", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"default/mypod",
+		"default/mypod2",
+		"default/mypod3",
+		"other/mypod4",
+	}, listPods(podList))
+
+	podList, nodeList, err := listPodsAndNodes(clientset, false, "a=test", "hello=world", "", "", "", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mynode"}, listNodes(nodeList))
+	assert.Equal(t, []string{
+		"default/mypod",
+		"default/mypod3",
+	}, listPods(podList))
+}
+
+func TestGetWorkloadOwners(t *testing.T) {
+	controller := true
+	clientset := fake.NewSimpleClientset(
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "api-7c9f8d",
+				Namespace:       "default",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "api", Controller: &controller}},
+			},
+		},
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "orphan",
+				Namespace: "default",
+			},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "backup-28401",
+				Namespace:       "ops",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "CronJob", Name: "backup", Controller: &controller}},
+			},
+		},
+	)
+
+	owners, err := getWorkloadOwners(context.TODO(), clientset, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]workloadRef{
+		"default/ReplicaSet/api-7c9f8d": {kind: "Deployment", name: "api"},
+		"ops/Job/backup-28401":          {kind: "CronJob", name: "backup"},
+	}, owners)
+
+	owners, err = getWorkloadOwners(context.TODO(), clientset, 0, "ops")
+	assert.NoError(t, err)
+	assert.Len(t, owners, 1)
+}
+
+func TestCollect(t *testing.T) {
+	n := node("mynode", map[string]string{"hello": "world"}, false)
+	n.Status.Allocatable = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("2"),
+		corev1.ResourceMemory: resource.MustParse("4Gi"),
+		corev1.ResourcePods:   resource.MustParse("110"),
+	}
+	p := pod("mynode", "default", "mypod", map[string]string{"a": "test"})
+	p.Spec.Containers = []corev1.Container{{
+		Name: "app",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")},
+			Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+		},
+	}}
+	clientset := fake.NewSimpleClientset(n, p)
+
+	cm, err := Collect(context.TODO(), clientset, nil, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory}, cm.ResourceNames())
+	assert.Equal(t, int64(1), cm.PodCount())
+	assert.Equal(t, int64(110), cm.AllocatablePods())
+	assert.Nil(t, cm.Unscheduled())
+
+	cpu := cm.Resource(corev1.ResourceCPU)
+	assert.Equal(t, "2", quantityString(cpu.Allocatable()))
+	assert.Equal(t, "250m", quantityString(cpu.Request()))
+	assert.Equal(t, "500m", quantityString(cpu.Limit()))
+
+	nodes := cm.Nodes()
+	assert.Len(t, nodes, 1)
+	assert.Equal(t, "mynode", nodes[0].Name())
+	assert.Equal(t, map[string]string{"hello": "world"}, nodes[0].Labels())
+
+	pods := nodes[0].Pods()
+	assert.Len(t, pods, 1)
+	assert.Equal(t, "default", pods[0].Namespace())
+	assert.Equal(t, "mypod", pods[0].Name())
+	kind, name := pods[0].Workload()
+	assert.Equal(t, "Pod", kind)
+	assert.Equal(t, "mypod", name)
+
+	containers := pods[0].Containers()
+	assert.Len(t, containers, 1)
+	assert.Equal(t, "app", containers[0].Name())
+	assert.Equal(t, "250m", quantityString(containers[0].Resource(corev1.ResourceCPU).Request()))
+
+	// Quantities are copies, so changing them leaves the metric intact.
+	request := cpu.Request()
+	request.Add(resource.MustParse("1"))
+	assert.Equal(t, "250m", quantityString(cpu.Request()))
+}
+
+func TestCollectErrors(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("connection refused")
+	})
+
+	_, err := Collect(context.TODO(), clientset, nil, Options{})
+	var listErr *ListError
+	assert.True(t, errors.As(err, &listErr))
+	assert.Equal(t, "Nodes", listErr.Resource)
+	assert.EqualError(t, err, "listing Nodes: connection refused")
+
+	_, err = Collect(context.TODO(), fake.NewSimpleClientset(), nil, Options{ShowUtil: true})
+	var connErr *ConnectionError
+	assert.True(t, errors.As(err, &connErr))
+	assert.Equal(t, MetricsAPI, connErr.API)
+}
+
+func TestListPages(t *testing.T) {
+	pods := []corev1.Pod{
+		*pod("mynode", "default", "mypod", nil),
+		*pod("mynode", "default", "mypod2", nil),
+		*pod("mynode", "default", "mypod3", nil),
+		*pod("mynode", "default", "mypod4", nil),
+		*pod("mynode", "default", "mypod5", nil),
+	}
+	requests := []metav1.ListOptions{}
+	list := func(ctx context.Context, listOpts metav1.ListOptions) (*corev1.PodList, error) {
+		requests = append(requests, listOpts)
+		start := 0
+		if listOpts.Continue != "" {
+			start = int(listOpts.Continue[0] - '0')
+		}
+		end := len(pods)
+		if listOpts.Limit > 0 && start+int(listOpts.Limit) < end {
+			end = start + int(listOpts.Limit)
+		}
+		page := &corev1.PodList{Items: pods[start:end]}
+		if end < len(pods) {
+			page.Continue = string(rune('0' + end))
+		}
+		return page, nil
+	}
+
+	pages := [][]string{}
+	err := listPages(context.TODO(), 2, metav1.ListOptions{LabelSelector: "a=test"}, list, func(page *corev1.PodList) {
+		pages = append(pages, listPods(page))
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"default/mypod", "default/mypod2"},
+		{"default/mypod3", "default/mypod4"},
+		{"default/mypod5"},
+	}, pages)
+	assert.Equal(t, []metav1.ListOptions{
+		{LabelSelector: "a=test", Limit: 2},
+		{LabelSelector: "a=test", Limit: 2, Continue: "2"},
+		{LabelSelector: "a=test", Limit: 2, Continue: "4"},
+	}, requests)
+
+	requests = nil
+	pages = nil
+	err = listPages(context.TODO(), 0, metav1.ListOptions{}, list, func(page *corev1.PodList) {
+		pages = append(pages, listPods(page))
+	})
+	assert.NoError(t, err)
+	assert.Len(t, pages, 1)
+	assert.Len(t, requests, 1)
+
+	err = listPages(context.TODO(), 2, metav1.ListOptions{}, func(ctx context.Context, listOpts metav1.ListOptions) (*corev1.PodList, error) {
+		return nil, errors.New("too many requests")
+	}, func(page *corev1.PodList) {})
+	assert.EqualError(t, err, "too many requests")
+}
+
+func TestEachPodFieldSelectors(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		node("mynode", map[string]string{"hello": "world"}, false),
+		node("mynode2", map[string]string{"hello": "world"}, false),
+		node("mynode3", map[string]string{}, false),
+		pod("mynode", "default", "mypod", map[string]string{"a": "test"}),
+		pod("mynode2", "default", "mypod2", map[string]string{"a": "test"}),
+		pod("mynode3", "default", "mypod3", map[string]string{"a": "test"}),
+		pod("", "default", "mypod4", map[string]string{"a": "test"}),
+	)
+
+	var mu sync.Mutex
+	selectors := []string{}
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		selectors = append(selectors, action.(k8stesting.ListAction).GetListRestrictions().Fields.String())
+		return false, nil, nil
+	})
+
+	nodeList, err := getNodes(context.TODO(), clientset, 0, false, "hello=world", "")
+	assert.NoError(t, err)
+
+	collect := func(fieldSelector string, perNode bool) ([]string, error) {
+		selectors = []string{}
+		podList := &corev1.PodList{}
+		err := eachPod(context.TODO(), clientset, 0, nodeList, nil, "", fieldSelector, "", true, perNode, func(pod *corev1.Pod) {
+			podList.Items = append(podList.Items, *pod)
+		})
+		pods := listPods(podList)
+		sort.Strings(pods)
+		sort.Strings(selectors)
+		return pods, err
+	}
+
+	pods, err := collect("metadata.namespace=default", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"default/mypod", "default/mypod2", "default/mypod4"}, pods)
+	assert.Equal(t, []string{
+		"metadata.namespace=default,status.phase!=Failed,status.phase!=Succeeded",
+	}, selectors)
+
+	// The fake clientset ignores field selectors, so each list returns every
+	// pod and only those on the node being listed must be kept.
+	pods, err = collect("", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"default/mypod", "default/mypod2", "default/mypod4"}, pods)
+	assert.Equal(t, []string{
+		"spec.nodeName=,status.phase!=Failed,status.phase!=Succeeded",
+		"spec.nodeName=mynode,status.phase!=Failed,status.phase!=Succeeded",
+		"spec.nodeName=mynode2,status.phase!=Failed,status.phase!=Succeeded",
+	}, selectors)
+
+	_, err = collect("metadata.name", false)
+	var optErr *OptionError
+	assert.True(t, errors.As(err, &optErr))
+	assert.Equal(t, "field selector", optErr.Option)
+}
+
+// listPodsAndNodes lists nodes and namespaces and collects the pods passed
+// to eachPod, in the order they were listed.
+func listPodsAndNodes(clientset *fake.Clientset, excludeTainted bool, podLabels, nodeLabels, nodeTaints, namespaceLabels, namespace string, includePending bool) (*corev1.PodList, *corev1.NodeList, error) {
+	nodeList, err := getNodes(context.TODO(), clientset, 0, excludeTainted, nodeLabels, nodeTaints)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var namespaces map[string]bool
+	if namespace == "" && namespaceLabels != "" {
+		namespaces, err = getNamespaces(context.TODO(), clientset, 0, namespaceLabels)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	podList := &corev1.PodList{}
+	err = eachPod(context.TODO(), clientset, 0, nodeList, namespaces, podLabels, "", namespace, includePending, false, func(pod *corev1.Pod) {
+		podList.Items = append(podList.Items, *pod)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return podList, nodeList, nil
+}
+
 func node(name string, labels map[string]string, tainted bool) *corev1.Node {
 	n := &corev1.Node{
 		TypeMeta: metav1.TypeMeta{
@@ -214,3 +518,7 @@ func pod(node, namespace, name string, labels map[string]string) *corev1.Pod {
 		},
 	}
 }
+
+func quantityString(q resource.Quantity) string {
+	return q.String()
+}
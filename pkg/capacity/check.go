@@ -0,0 +1,478 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	//ClusterScope is the constant value for checking the totals of a cluster
+	ClusterScope string = "cluster"
+	//NodeScope is the constant value for checking each node, or each group of nodes
+	NodeScope string = "node"
+	//NamespaceScope is the constant value for checking the totals of each namespace
+	NamespaceScope string = "namespace"
+	//PodScope is the constant value for checking each pod
+	PodScope string = "pod"
+	//ContainerScope is the constant value for checking each container
+	ContainerScope string = "container"
+
+	//JUnitOutput is the constant value for output type JUnit XML
+	JUnitOutput string = "junit"
+)
+
+// thresholdOperators are the comparisons a threshold can make, with those
+// that are prefixes of others last so they are matched correctly.
+var thresholdOperators = []string{">=", "<=", ">", "<"}
+
+// SupportedCheckScopes returns a string list of the scopes thresholds can
+// be checked against
+func SupportedCheckScopes() []string {
+	return []string{
+		ClusterScope,
+		NodeScope,
+		NamespaceScope,
+		PodScope,
+		ContainerScope,
+	}
+}
+
+// SupportedCheckOutputs returns a string list of output formats supported
+// by the check command
+func SupportedCheckOutputs() []string {
+	return []string{
+		TableOutput,
+		JSONOutput,
+		YAMLOutput,
+		JUnitOutput,
+	}
+}
+
+// threshold is a condition such as "node:cpu.request.percentage>85" that
+// fails a check when any row in its scope meets it.
+type threshold struct {
+	expr      string
+	scope     string
+	attribute string
+	operator  string
+	value     resource.Quantity
+
+	// name identifies the check in output. It is the name of a policy rule,
+	// or the expression of a threshold given on the command line.
+	name        string
+	description string
+	// namespaces limits the rows that are checked to those in any of the
+	// given namespaces, if it is not empty.
+	namespaces map[string]bool
+
+	// resourceName, field and percentage are parsed from attribute, which
+	// is either a resource sort attribute or "pod.count".
+	resourceName corev1.ResourceName
+	field        string
+	percentage   bool
+}
+
+// violation is a row that met a threshold.
+type violation struct {
+	threshold *threshold
+	name      string
+	value     string
+}
+
+type listCheckResults struct {
+	Checks []*listCheck `json:"checks"`
+}
+
+type listCheck struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Threshold   string           `json:"threshold"`
+	Passed      bool             `json:"passed"`
+	Violations  []*listViolation `json:"violations,omitempty"`
+}
+
+type listViolation struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// parseThreshold parses an expression of the form
+// <scope>:<attribute><operator><value>, where attribute is any resource
+// sort attribute or pod.count.
+func parseThreshold(expr string) (*threshold, error) {
+	scope, condition, found := strings.Cut(expr, ":")
+	if !found {
+		return nil, fmt.Errorf("%q must be of the form <scope>:<attribute><operator><value>", expr)
+	}
+
+	t := &threshold{expr: expr, name: expr, scope: scope}
+	supported := false
+	for _, s := range SupportedCheckScopes() {
+		if s == scope {
+			supported = true
+		}
+	}
+	if !supported {
+		return nil, fmt.Errorf("unsupported scope %q in %q, we only support: %v", scope, expr, SupportedCheckScopes())
+	}
+
+	for _, op := range thresholdOperators {
+		if i := strings.Index(condition, op); i >= 0 {
+			t.attribute, t.operator = condition[:i], op
+			condition = condition[i+len(op):]
+			break
+		}
+	}
+	if t.operator == "" {
+		return nil, fmt.Errorf("%q must compare with one of %v", expr, thresholdOperators)
+	}
+
+	value, err := resource.ParseQuantity(condition)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q in %q: %v", condition, expr, err)
+	}
+	t.value = value
+
+	if t.attribute == "pod.count" {
+		if scope == PodScope || scope == ContainerScope {
+			return nil, fmt.Errorf("pod.count can only be checked for the cluster, nodes or namespaces in %q", expr)
+		}
+		return t, nil
+	}
+
+	var ok bool
+	t.resourceName, t.field, t.percentage, ok = parseResourceSortAttribute(t.attribute)
+	if !ok {
+		return nil, fmt.Errorf("unsupported attribute %q in %q", t.attribute, expr)
+	}
+	return t, nil
+}
+
+// parseThresholds parses each of the given threshold expressions.
+func parseThresholds(exprs []string) ([]*threshold, error) {
+	thresholds := make([]*threshold, 0, len(exprs))
+	for _, expr := range exprs {
+		t, err := parseThreshold(expr)
+		if err != nil {
+			return nil, &OptionError{Option: "fail-if", Err: err}
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, nil
+}
+
+// FetchAndCheck gathers cluster resource data and checks it against each of
+// the FailIf thresholds and the rules of the Policy file, outputting the
+// rows that fail them. A ThresholdError is returned if any failed.
+func FetchAndCheck(ctx context.Context, opts Options) error {
+	thresholds, err := parseThresholds(opts.FailIf)
+	if err != nil {
+		return err
+	}
+	if opts.Policy != "" {
+		rules, err := loadPolicy(opts.Policy)
+		if err != nil {
+			return err
+		}
+		thresholds = append(thresholds, rules...)
+	}
+
+	// Resources and utilization referred to by thresholds are gathered even
+	// if they have not been requested.
+	resources := []string{}
+	for _, name := range opts.resourceNames() {
+		resources = append(resources, string(name))
+	}
+	for _, t := range thresholds {
+		if t.resourceName != "" {
+			resources = append(resources, string(t.resourceName))
+		}
+		if strings.HasPrefix(t.field, "util") {
+			opts.ShowUtil = true
+		}
+	}
+	opts.Resources = resources
+
+	cm, err := fetchClusterMetric(ctx, opts, opts.GroupBy == WorkloadGroup)
+	if err != nil {
+		return err
+	}
+
+	violations := checkThresholds(cm.group(opts), thresholds)
+	if err := printChecks(thresholds, violations, opts.OutputFormat); err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	thresholdErr := &ThresholdError{Checks: len(thresholds)}
+	for _, v := range violations {
+		if n := len(thresholdErr.Failed); n == 0 || thresholdErr.Failed[n-1] != v.threshold.name {
+			thresholdErr.Failed = append(thresholdErr.Failed, v.threshold.name)
+		}
+	}
+	return thresholdErr
+}
+
+// checkThresholds returns the rows of cm that meet each threshold, in the
+// order the thresholds were given.
+func checkThresholds(cm *ClusterMetric, thresholds []*threshold) []*violation {
+	violations := []*violation{}
+	for _, t := range thresholds {
+		check := func(name string, resources map[corev1.ResourceName]*ResourceMetric, pc *podCount) {
+			if value, met := t.check(resources, pc); met {
+				violations = append(violations, &violation{threshold: t, name: name, value: value})
+			}
+		}
+
+		switch t.scope {
+		case ClusterScope:
+			check(VoidValue, cm.resources, cm.podCount)
+		case NodeScope:
+			for _, nm := range cm.getSortedNodeMetrics("name") {
+				check(nm.name, nm.resources, nm.podCount)
+			}
+		case NamespaceScope:
+			for _, nm := range cm.groupBy(NamespaceGroup).getSortedNodeMetrics("name") {
+				if t.includes(nm.name) {
+					check(nm.name, nm.resources, nm.podCount)
+				}
+			}
+		default:
+			nodeMetrics := cm.getSortedNodeMetrics("name")
+			if cm.unscheduled != nil {
+				nodeMetrics = append(nodeMetrics, cm.unscheduled)
+			}
+			for _, nm := range nodeMetrics {
+				for _, pm := range nm.getSortedPodMetrics("name") {
+					if !t.includes(pm.namespace) {
+						continue
+					}
+					podName := fmt.Sprintf("%s/%s", pm.namespace, pm.name)
+					if t.scope == PodScope {
+						check(podName, pm.resources, nil)
+						continue
+					}
+					for _, ctm := range pm.getSortedContainerMetrics("name") {
+						if ctm.containerType == OverheadContainerType {
+							continue
+						}
+						check(fmt.Sprintf("%s/%s", podName, ctm.name), ctm.resources, nil)
+					}
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// includes returns true if rows in the given namespace are checked by t.
+func (t *threshold) includes(namespace string) bool {
+	return len(t.namespaces) == 0 || t.namespaces[namespace]
+}
+
+// check returns the value of a row that t is compared to, and whether the
+// row meets t. Percentages of resources without an allocatable amount, such
+// as those of pods that have not been scheduled, never meet a threshold.
+func (t *threshold) check(resources map[corev1.ResourceName]*ResourceMetric, pc *podCount) (string, bool) {
+	if t.attribute == "pod.count" {
+		return fmt.Sprintf("%d", pc.current), t.compare(cmp.Compare(pc.current, t.value.Value()))
+	}
+
+	rm := resources[t.resourceName]
+	if rm == nil {
+		return "", false
+	}
+
+	q := rm.field(t.field)
+	if !t.percentage {
+		return rm.valueFunction()(q), t.compare(q.Cmp(t.value))
+	}
+
+	if rm.allocatable.MilliValue() == 0 {
+		return "", false
+	}
+	percent := float64(q.MilliValue()) / float64(rm.allocatable.MilliValue()) * 100
+	return fmt.Sprintf("%.1f%%", percent), t.compare(cmp.Compare(percent, t.value.AsApproximateFloat64()))
+}
+
+// compare returns whether a value meets the threshold, given the result of
+// comparing the value to it.
+func (t *threshold) compare(result int) bool {
+	switch t.operator {
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	default:
+		return result <= 0
+	}
+}
+
+func printChecks(thresholds []*threshold, violations []*violation, outputType string) error {
+	switch outputType {
+	case TableOutput:
+		printViolationTable(violations)
+	case JSONOutput, YAMLOutput:
+		return printCheckList(buildListCheckResults(thresholds, violations), outputType)
+	case JUnitOutput:
+		return printCheckJUnit(buildListCheckResults(thresholds, violations))
+	default:
+		return fmt.Errorf("unsupported output type: %s", outputType)
+	}
+	return nil
+}
+
+func printViolationTable(violations []*violation) {
+	if len(violations) == 0 {
+		fmt.Println("No thresholds were exceeded")
+		return
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(w, strings.Join([]string{"CHECK", "NAME", "VALUE"}, "\t "))
+	for _, v := range violations {
+		_, _ = fmt.Fprintln(w, strings.Join([]string{v.threshold.name, v.name, v.value}, "\t "))
+	}
+
+	err := w.Flush()
+	if err != nil {
+		fmt.Printf("Error writing to table: %s", err)
+	}
+}
+
+// buildListCheckResults returns the result of each threshold, along with
+// the rows that failed it.
+func buildListCheckResults(thresholds []*threshold, violations []*violation) *listCheckResults {
+	results := &listCheckResults{Checks: []*listCheck{}}
+	for _, t := range thresholds {
+		check := &listCheck{
+			Name:        t.name,
+			Description: t.description,
+			Threshold:   t.expr,
+			Passed:      true,
+		}
+		for _, v := range violations {
+			if v.threshold == t {
+				check.Passed = false
+				check.Violations = append(check.Violations, &listViolation{Name: v.name, Value: v.value})
+			}
+		}
+		results.Checks = append(results.Checks, check)
+	}
+	return results
+}
+
+func printCheckList(results *listCheckResults, outputType string) error {
+	// Thresholds are compared with < and >, which are left unescaped.
+	var jsonRaw bytes.Buffer
+	encoder := json.NewEncoder(&jsonRaw)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(results); err != nil {
+		return fmt.Errorf("marshalling JSON: %w", err)
+	}
+
+	if outputType == JSONOutput {
+		fmt.Print(jsonRaw.String())
+		return nil
+	}
+
+	yamlRaw, err := yaml.JSONToYAML(jsonRaw.Bytes())
+	if err != nil {
+		return fmt.Errorf("converting JSON to YAML: %w", err)
+	}
+	fmt.Printf("%s", yamlRaw)
+	return nil
+}
+
+// buildJUnitReport returns a JUnit XML report in which each check is a test
+// case, with the rows that failed it listed in its failure.
+func buildJUnitReport(results *listCheckResults) junitTestSuites {
+	suite := junitTestSuite{Name: "kube-capacity", Tests: len(results.Checks)}
+	for _, check := range results.Checks {
+		scope, _, _ := strings.Cut(check.Threshold, ":")
+		tc := junitTestCase{Name: check.Name, ClassName: "kube-capacity." + scope}
+		if !check.Passed {
+			suite.Failures++
+			rows := make([]string, 0, len(check.Violations))
+			for _, v := range check.Violations {
+				rows = append(rows, fmt.Sprintf("%s: %s", v.Name, v.Value))
+			}
+			message := fmt.Sprintf("%s failed for %d rows", check.Threshold, len(check.Violations))
+			if check.Description != "" {
+				message = fmt.Sprintf("%s: %s", check.Description, message)
+			}
+			tc.Failure = &junitFailure{
+				Message: message,
+				Type:    "threshold",
+				Text:    strings.Join(rows, "\n"),
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	return junitTestSuites{Suites: []junitTestSuite{suite}}
+}
+
+func printCheckJUnit(results *listCheckResults) error {
+	xmlRaw, err := xml.MarshalIndent(buildJUnitReport(results), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling JUnit XML: %w", err)
+	}
+	fmt.Printf("%s%s\n", xml.Header, xmlRaw)
+	return nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseThreshold(t *testing.T) {
+	var testCases = []struct {
+		expr      string
+		scope     string
+		attribute string
+		operator  string
+		value     string
+		err       bool
+	}{
+		{"node:cpu.request.percentage>85", NodeScope, "cpu.request.percentage", ">", "85", false},
+		{"cluster:mem.util.percentage>=70.5", ClusterScope, "mem.util.percentage", ">=", "70500m", false},
+		{"container:mem.limit<=512Mi", ContainerScope, "mem.limit", "<=", "512Mi", false},
+		{"node:pod.count<10", NodeScope, "pod.count", "<", "10", false},
+		{"pod:cpu.util.p95>1", PodScope, "cpu.util.p95", ">", "1", false},
+		{"pod:pod.count>10", "", "", "", "", true},
+		{"rack:cpu.request>1", "", "", "", "", true},
+		{"node:cpu.request=1", "", "", "", "", true},
+		{"node:cpu.foo>1", "", "", "", "", true},
+		{"node:cpu.request>lots", "", "", "", "", true},
+		{"cpu.request>1", "", "", "", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			th, err := parseThreshold(tc.expr)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.scope, th.scope)
+			assert.Equal(t, tc.attribute, th.attribute)
+			assert.Equal(t, tc.operator, th.operator)
+			assert.Equal(t, tc.value, quantityString(th.value))
+		})
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	cm := buildClusterMetric(getGroupTestPodList(), nil, getGroupTestNodeList(), nil, defaultResourceNames)
+
+	thresholds, err := parseThresholds([]string{
+		"node:cpu.request.percentage>17",
+		"cluster:pod.count>=3",
+		"pod:mem.request>=1Gi",
+		"container:cpu.limit>500m",
+		"cluster:cpu.limit.percentage>50",
+	})
+	assert.NoError(t, err)
+
+	violations := checkThresholds(&cm, thresholds)
+	rows := [][]string{}
+	for _, v := range violations {
+		rows = append(rows, []string{v.threshold.expr, v.name, v.value})
+	}
+	assert.Equal(t, [][]string{
+		{"node:cpu.request.percentage>17", "node-2", "20.0%"},
+		{"cluster:pod.count>=3", "*", "3"},
+		{"pod:mem.request>=1Gi", "kube-system/dns", "1024Mi"},
+		{"container:cpu.limit>500m", "default/web-2/app", "800m"},
+	}, rows)
+
+	// Nodes are checked as groups when grouped by label.
+	grouped := cm.group(Options{GroupNodesBy: "topology.kubernetes.io/zone"})
+	thresholds, err = parseThresholds([]string{"node:mem.request>1Gi"})
+	assert.NoError(t, err)
+	violations = checkThresholds(grouped, thresholds)
+	assert.Len(t, violations, 1)
+	assert.Equal(t, "zone-a", violations[0].name)
+}
+
+func TestFetchAndCheck(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "list.json")
+	assert.NoError(t, os.WriteFile(file, []byte(testFileList), 0o600))
+
+	opts := Options{
+		FromFiles:    []string{file},
+		Resources:    []string{"cpu"},
+		OutputFormat: TableOutput,
+		FailIf:       []string{"node:cpu.request.percentage>10", "node:mem.request>1Gi"},
+	}
+	err := FetchAndCheck(context.TODO(), opts)
+	var thresholdErr *ThresholdError
+	assert.True(t, errors.As(err, &thresholdErr))
+	assert.Equal(t, []string{"node:cpu.request.percentage>10"}, thresholdErr.Failed)
+	assert.Equal(t, 2, thresholdErr.Checks)
+	assert.EqualError(t, err, "1 of 2 checks failed: node:cpu.request.percentage>10")
+
+	opts.FailIf = []string{"cluster:cpu.request.percentage>50"}
+	assert.NoError(t, FetchAndCheck(context.TODO(), opts))
+
+	opts.FailIf = []string{"node:cpu.request"}
+	var optErr *OptionError
+	assert.True(t, errors.As(FetchAndCheck(context.TODO(), opts), &optErr))
+}
+
+func TestCheckPolicyRules(t *testing.T) {
+	cm := buildClusterMetric(getGroupTestPodList(), nil, getGroupTestNodeList(), nil, defaultResourceNames)
+
+	namespaced, err := parseThreshold("namespace:cpu.request>250m")
+	assert.NoError(t, err)
+	namespaced.name = "namespace-cpu"
+	podCount, err := parseThreshold("namespace:pod.count>=1")
+	assert.NoError(t, err)
+	podCount.namespaces = map[string]bool{"kube-system": true}
+	limited, err := parseThreshold("container:cpu.limit>=200m")
+	assert.NoError(t, err)
+	limited.name = "cpu-limits"
+	limited.description = "Containers in kube-system must not have large CPU limits"
+	limited.namespaces = map[string]bool{"kube-system": true}
+	passing, err := parseThreshold("cluster:pod.count>100")
+	assert.NoError(t, err)
+
+	thresholds := []*threshold{namespaced, podCount, limited, passing}
+	violations := checkThresholds(&cm, thresholds)
+	rows := [][]string{}
+	for _, v := range violations {
+		rows = append(rows, []string{v.threshold.name, v.name, v.value})
+	}
+	assert.Equal(t, [][]string{
+		{"namespace-cpu", "default", "600m"},
+		{"namespace:pod.count>=1", "kube-system", "1"},
+		{"cpu-limits", "kube-system/dns/app", "200m"},
+	}, rows)
+
+	results := buildListCheckResults(thresholds, violations)
+	assert.Len(t, results.Checks, 4)
+	assert.False(t, results.Checks[0].Passed)
+	assert.Equal(t, "namespace:cpu.request>250m", results.Checks[0].Threshold)
+	assert.Equal(t, []*listViolation{{Name: "default", Value: "600m"}}, results.Checks[0].Violations)
+	assert.True(t, results.Checks[3].Passed)
+	assert.Empty(t, results.Checks[3].Violations)
+
+	xmlRaw, err := xml.MarshalIndent(buildJUnitReport(results), "", "  ")
+	assert.NoError(t, err)
+	assert.Contains(t, string(xmlRaw), `<testsuite name="kube-capacity" tests="4" failures="3">`)
+	assert.Contains(t, string(xmlRaw), `<testcase name="cpu-limits" classname="kube-capacity.container">
+      <failure message="Containers in kube-system must not have large CPU limits: container:cpu.limit&gt;=200m failed for 1 rows" type="threshold">kube-system/dns/app: 200m</failure>`)
+	assert.Contains(t, string(xmlRaw), `<testcase name="cluster:pod.count&gt;100" classname="kube-capacity.cluster"></testcase>`)
+}
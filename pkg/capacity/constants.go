@@ -18,4 +18,10 @@ package capacity
 const (
 	VoidValue           = "*"
 	CSVStringTerminator = "\""
+	UnscheduledNodeName = "<unscheduled>"
+	NoneGroupName       = "<none>"
+	PodOverheadName     = "<overhead>"
+	HighlightStart      = "\033[7m"
+	HighlightEnd        = "\033[0m"
+	ClearScreen         = "\033[H\033[2J"
 )
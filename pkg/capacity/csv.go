@@ -19,84 +19,171 @@ import (
 	"io"
 	"os"
 	"strings"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
 type csvPrinter struct {
-	cm   *clusterMetric
+	cm   *ClusterMetric
 	file io.Writer
 	opts Options
+
+	// clusters holds the clusters to print when gathering data from
+	// several contexts, in which case cm holds their fleet totals.
+	clusters []*contextMetric
+	// cluster is the name of the cluster the lines being printed belong to.
+	cluster string
 }
 
 type csvLine struct {
-	node                     string
-	namespace                string
-	pod                      string
-	container                string
-	cpuCapacity              string
-	cpuRequests              string
-	cpuRequestsPercentage    string
-	cpuLimits                string
-	cpuLimitsPercentage      string
-	cpuUtil                  string
-	cpuUtilPercentage        string
-	memoryCapacity           string
-	memoryRequests           string
-	memoryRequestsPercentage string
-	memoryLimits             string
-	memoryLimitsPercentage   string
-	memoryUtil               string
-	memoryUtilPercentage     string
-	podCountCurrent          string
-	podCountAllocatable      string
+	cluster             string
+	node                string
+	namespace           string
+	pod                 string
+	container           string
+	containerType       string
+	qosClass            string
+	priorityClass       string
+	resources           map[corev1.ResourceName]*csvResourceLine
+	replicas            string
+	podCountCurrent     string
+	podCountAllocatable string
+	reason              string
+}
+
+type csvResourceLine struct {
+	capacity           string
+	requests           string
+	requestsPercentage string
+	limits             string
+	limitsPercentage   string
+	util               string
+	utilPercentage     string
+	// utilStats and utilStatPercentages hold sampled utilization, keyed by
+	// statistic.
+	utilStats           map[string]string
+	utilStatPercentages map[string]string
 }
 
-var csvHeaderStrings = csvLine{
-	node:                     "NODE",
-	namespace:                "NAMESPACE",
-	pod:                      "POD",
-	container:                "CONTAINER",
-	cpuCapacity:              "CPU CAPACITY (milli)",
-	cpuRequests:              "CPU REQUESTS",
-	cpuRequestsPercentage:    "CPU REQUESTS %%",
-	cpuLimits:                "CPU LIMITS",
-	cpuLimitsPercentage:      "CPU LIMITS %%",
-	cpuUtil:                  "CPU UTIL",
-	cpuUtilPercentage:        "CPU UTIL %%",
-	memoryCapacity:           "MEMORY CAPACITY (Mi)",
-	memoryRequests:           "MEMORY REQUESTS",
-	memoryRequestsPercentage: "MEMORY REQUESTS %%",
-	memoryLimits:             "MEMORY LIMITS",
-	memoryLimitsPercentage:   "MEMORY LIMITS %%",
-	memoryUtil:               "MEMORY UTIL",
-	memoryUtilPercentage:     "MEMORY UTIL %%",
-	podCountCurrent:          "POD COUNT CURRENT",
-	podCountAllocatable:      "POD COUNT ALLOCATABLE",
+func (cp *csvPrinter) headerLine() *csvLine {
+	cl := &csvLine{
+		cluster:             "CLUSTER",
+		node:                groupHeader(cp.opts),
+		namespace:           "NAMESPACE",
+		pod:                 "POD",
+		container:           "CONTAINER",
+		containerType:       "TYPE",
+		qosClass:            "QOS CLASS",
+		priorityClass:       "PRIORITY CLASS",
+		resources:           map[corev1.ResourceName]*csvResourceLine{},
+		replicas:            "REPLICAS",
+		podCountCurrent:     "POD COUNT CURRENT",
+		podCountAllocatable: "POD COUNT ALLOCATABLE",
+		reason:              "REASON",
+	}
+
+	for _, name := range cp.opts.resourceNames() {
+		displayName := resourceDisplayName(name)
+		capacity := displayName + " CAPACITY"
+		if unit := resourceCSVUnit(string(name)); unit != "" {
+			capacity = fmt.Sprintf("%s (%s)", capacity, unit)
+		}
+		cl.resources[name] = &csvResourceLine{
+			capacity:            capacity,
+			requests:            displayName + " REQUESTS",
+			requestsPercentage:  displayName + " REQUESTS %%",
+			limits:              displayName + " LIMITS",
+			limitsPercentage:    displayName + " LIMITS %%",
+			util:                displayName + " UTIL",
+			utilPercentage:      displayName + " UTIL %%",
+			utilStats:           map[string]string{},
+			utilStatPercentages: map[string]string{},
+		}
+		for _, stat := range sampleStats {
+			statName := fmt.Sprintf("%s UTIL %s", displayName, strings.ToUpper(stat))
+			cl.resources[name].utilStats[stat] = statName
+			cl.resources[name].utilStatPercentages[stat] = statName + " %%"
+		}
+	}
+
+	return cl
 }
 
 func (cp *csvPrinter) Print(outputType string) {
 
-	cp.file = os.Stdout
+	if cp.file == nil {
+		cp.file = os.Stdout
+	}
 
-	sortedNodeMetrics := cp.cm.getSortedNodeMetrics(cp.opts.SortBy)
+	cp.printLine(cp.headerLine())
 
-	cp.printLine(&csvHeaderStrings)
+	if cp.clusters != nil {
+		cp.printContexts()
+	} else {
+		cp.printCluster(cp.cm, false)
+	}
+}
 
-	if len(sortedNodeMetrics) > 1 {
-		cp.printClusterLine()
+// printCluster prints the cluster line, if requested or if there is more
+// than one node, followed by each node.
+func (cp *csvPrinter) printCluster(cm *ClusterMetric, clusterLine bool) {
+	sortedNodeMetrics := cm.getSortedNodeMetrics(cp.opts.SortBy)
+
+	if clusterLine || len(sortedNodeMetrics) > 1 {
+		cp.printClusterLine(cm)
+		for _, class := range getSortedClassMetrics(cm.classes, cp.opts.SortBy) {
+			cp.printClassLine(VoidValue, class)
+		}
 	}
 
 	for _, nm := range sortedNodeMetrics {
-		cp.printNodeLine(nm.name, nm)
-
-		if cp.opts.ShowPods || cp.opts.ShowContainers {
-			podMetrics := nm.getSortedPodMetrics(cp.opts.SortBy)
-			for _, pm := range podMetrics {
-				cp.printPodLine(nm.name, pm)
-				if cp.opts.ShowContainers {
-					containerMetrics := pm.getSortedContainerMetrics(cp.opts.SortBy)
-					for _, containerMetric := range containerMetrics {
-						cp.printContainerLine(nm.name, pm, containerMetric)
-					}
+		cp.printNode(nm)
+	}
+
+	if cm.unscheduled != nil {
+		cp.printNode(cm.unscheduled)
+	}
+}
+
+// printContexts prints the fleet totals followed by each cluster that was
+// reached.
+func (cp *csvPrinter) printContexts() {
+	cp.cluster = VoidValue
+	cp.printClusterLine(cp.cm)
+
+	for _, c := range cp.clusters {
+		if c.err != nil {
+			continue
+		}
+		cp.cluster = c.name
+		cp.printCluster(c.cm, true)
+	}
+}
+
+func (cp *csvPrinter) printNode(nm *NodeMetric) {
+	cp.printNodeLine(nm.name, nm)
+
+	if nm.classes == nil {
+		cp.printPods(nm.name, nm)
+		return
+	}
+	for _, class := range getSortedClassMetrics(nm.classes, cp.opts.SortBy) {
+		cp.printClassLine(nm.name, class)
+		cp.printPods(nm.name, class)
+	}
+}
+
+// printPods prints the pods of a node or class, and their containers, if
+// requested.
+func (cp *csvPrinter) printPods(nodeName string, nm *NodeMetric) {
+	if cp.opts.ShowPods || cp.opts.ShowContainers {
+		podMetrics := nm.getSortedPodMetrics(cp.opts.SortBy)
+		for _, pm := range podMetrics {
+			cp.printPodLine(nodeName, pm)
+			if cp.opts.ShowContainers {
+				containerMetrics := pm.getSortedContainerMetrics(cp.opts.SortBy)
+				for _, containerMetric := range containerMetrics {
+					cp.printContainerLine(nodeName, pm, containerMetric)
 				}
 			}
 		}
@@ -104,6 +191,9 @@ func (cp *csvPrinter) Print(outputType string) {
 }
 
 func (cp *csvPrinter) printLine(cl *csvLine) {
+	if cl.cluster == "" {
+		cl.cluster = cp.cluster
+	}
 	separator := ","
 	if cp.opts.OutputFormat == TSVOutput {
 		separator = "\t"
@@ -115,10 +205,14 @@ func (cp *csvPrinter) printLine(cl *csvLine) {
 }
 
 func (cp *csvPrinter) getLineItems(cl *csvLine) []string {
-	lineItems := []string{CSVStringTerminator + cl.node + CSVStringTerminator}
+	lineItems := []string{}
+	if cp.clusters != nil {
+		lineItems = append(lineItems, CSVStringTerminator+cl.cluster+CSVStringTerminator)
+	}
+	lineItems = append(lineItems, CSVStringTerminator+cl.node+CSVStringTerminator)
 
 	if cp.opts.ShowContainers || cp.opts.ShowPods {
-		if cp.opts.Namespace == "" {
+		if cp.opts.Namespace == "" && cp.opts.GroupBy != NamespaceGroup {
 			lineItems = append(lineItems, CSVStringTerminator+cl.namespace+CSVStringTerminator)
 		}
 		lineItems = append(lineItems, CSVStringTerminator+cl.pod+CSVStringTerminator)
@@ -126,36 +220,45 @@ func (cp *csvPrinter) getLineItems(cl *csvLine) []string {
 
 	if cp.opts.ShowContainers {
 		lineItems = append(lineItems, CSVStringTerminator+cl.container+CSVStringTerminator)
+		lineItems = append(lineItems, CSVStringTerminator+cl.containerType+CSVStringTerminator)
 	}
 
-	lineItems = append(lineItems, cl.cpuCapacity)
-	if !cp.opts.HideRequests {
-		lineItems = append(lineItems, cl.cpuRequests)
-		lineItems = append(lineItems, cl.cpuRequestsPercentage)
+	if cp.opts.showQOSClass() {
+		lineItems = append(lineItems, CSVStringTerminator+cl.qosClass+CSVStringTerminator)
 	}
-	if !cp.opts.HideLimits {
-		lineItems = append(lineItems, cl.cpuLimits)
-		lineItems = append(lineItems, cl.cpuLimitsPercentage)
+	if cp.opts.showPriorityClass() {
+		lineItems = append(lineItems, CSVStringTerminator+cl.priorityClass+CSVStringTerminator)
 	}
 
-	if cp.opts.ShowUtil {
-		lineItems = append(lineItems, cl.cpuUtil)
-		lineItems = append(lineItems, cl.cpuUtilPercentage)
+	if cp.opts.GroupBy == WorkloadGroup {
+		lineItems = append(lineItems, cl.replicas)
 	}
 
-	lineItems = append(lineItems, cl.memoryCapacity)
-	if !cp.opts.HideRequests {
-		lineItems = append(lineItems, cl.memoryRequests)
-		lineItems = append(lineItems, cl.memoryRequestsPercentage)
-	}
-	if !cp.opts.HideLimits {
-		lineItems = append(lineItems, cl.memoryLimits)
-		lineItems = append(lineItems, cl.memoryLimitsPercentage)
-	}
+	for _, name := range cp.opts.resourceNames() {
+		rl := cl.resources[name]
+		if rl == nil {
+			rl = &csvResourceLine{}
+		}
+
+		lineItems = append(lineItems, rl.capacity)
+		if !cp.opts.HideRequests {
+			lineItems = append(lineItems, rl.requests)
+			lineItems = append(lineItems, rl.requestsPercentage)
+		}
+		if !cp.opts.HideLimits {
+			lineItems = append(lineItems, rl.limits)
+			lineItems = append(lineItems, rl.limitsPercentage)
+		}
 
-	if cp.opts.ShowUtil {
-		lineItems = append(lineItems, cl.memoryUtil)
-		lineItems = append(lineItems, cl.memoryUtilPercentage)
+		if cp.opts.sampled() {
+			for _, stat := range sampleStats {
+				lineItems = append(lineItems, rl.utilStats[stat])
+				lineItems = append(lineItems, rl.utilStatPercentages[stat])
+			}
+		} else if cp.opts.ShowUtil {
+			lineItems = append(lineItems, rl.util)
+			lineItems = append(lineItems, rl.utilPercentage)
+		}
 	}
 
 	if cp.opts.ShowPodCount {
@@ -163,101 +266,120 @@ func (cp *csvPrinter) getLineItems(cl *csvLine) []string {
 		lineItems = append(lineItems, cl.podCountAllocatable)
 	}
 
+	if cp.opts.ShowPending && (cp.opts.ShowPods || cp.opts.ShowContainers) {
+		lineItems = append(lineItems, CSVStringTerminator+cl.reason+CSVStringTerminator)
+	}
+
 	return lineItems
 }
 
-func (cp *csvPrinter) printClusterLine() {
+func (cp *csvPrinter) resourceLines(resources map[corev1.ResourceName]*ResourceMetric) map[corev1.ResourceName]*csvResourceLine {
+	lines := map[corev1.ResourceName]*csvResourceLine{}
+	for name, rm := range resources {
+		lines[name] = &csvResourceLine{
+			capacity:           rm.capacityString(),
+			requests:           rm.requestActualString(),
+			requestsPercentage: rm.requestPercentageString(),
+			limits:             rm.limitActualString(),
+			limitsPercentage:   rm.limitPercentageString(),
+			util:               rm.utilActualString(),
+			utilPercentage:     rm.utilPercentageString(),
+		}
+		if cp.opts.sampled() {
+			lines[name].utilStats = map[string]string{}
+			lines[name].utilStatPercentages = map[string]string{}
+			for _, stat := range sampleStats {
+				q := rm.utilizationStat(stat)
+				lines[name].utilStats[stat] = resourceCSVString(rm.resourceType, q)
+				lines[name].utilStatPercentages[stat] = resourceCSVPercentageString(q, rm.allocatable)
+			}
+		}
+	}
+	return lines
+}
+
+func (cp *csvPrinter) printClusterLine(cm *ClusterMetric) {
 	cp.printLine(&csvLine{
-		node:                     VoidValue,
-		namespace:                VoidValue,
-		pod:                      VoidValue,
-		container:                VoidValue,
-		cpuCapacity:              cp.cm.cpu.capacityString(),
-		cpuRequests:              cp.cm.cpu.requestActualString(),
-		cpuRequestsPercentage:    cp.cm.cpu.requestPercentageString(),
-		cpuLimits:                cp.cm.cpu.limitActualString(),
-		cpuLimitsPercentage:      cp.cm.cpu.limitPercentageString(),
-		cpuUtil:                  cp.cm.cpu.utilActualString(),
-		cpuUtilPercentage:        cp.cm.cpu.utilPercentageString(),
-		memoryCapacity:           cp.cm.memory.capacityString(),
-		memoryRequests:           cp.cm.memory.requestActualString(),
-		memoryRequestsPercentage: cp.cm.memory.requestPercentageString(),
-		memoryLimits:             cp.cm.memory.limitActualString(),
-		memoryLimitsPercentage:   cp.cm.memory.limitPercentageString(),
-		memoryUtil:               cp.cm.memory.utilActualString(),
-		memoryUtilPercentage:     cp.cm.memory.utilPercentageString(),
-		podCountCurrent:          cp.cm.podCount.podCountCurrentString(),
-		podCountAllocatable:      cp.cm.podCount.podCountAllocatableString(),
+		node:                VoidValue,
+		namespace:           VoidValue,
+		pod:                 VoidValue,
+		container:           VoidValue,
+		containerType:       VoidValue,
+		qosClass:            VoidValue,
+		priorityClass:       VoidValue,
+		resources:           cp.resourceLines(cm.resources),
+		replicas:            VoidValue,
+		podCountCurrent:     cm.podCount.podCountCurrentString(),
+		podCountAllocatable: cm.podCount.podCountAllocatableString(),
+		reason:              VoidValue,
 	})
 }
 
-func (cp *csvPrinter) printNodeLine(nodeName string, nm *nodeMetric) {
+func (cp *csvPrinter) printNodeLine(nodeName string, nm *NodeMetric) {
 	cp.printLine(&csvLine{
-		node:                     nodeName,
-		namespace:                VoidValue,
-		pod:                      VoidValue,
-		container:                VoidValue,
-		cpuCapacity:              nm.cpu.capacityString(),
-		cpuRequests:              nm.cpu.requestActualString(),
-		cpuRequestsPercentage:    nm.cpu.requestPercentageString(),
-		cpuLimits:                nm.cpu.limitActualString(),
-		cpuLimitsPercentage:      nm.cpu.limitPercentageString(),
-		cpuUtil:                  nm.cpu.utilActualString(),
-		cpuUtilPercentage:        nm.cpu.utilPercentageString(),
-		memoryCapacity:           nm.memory.capacityString(),
-		memoryRequests:           nm.memory.requestActualString(),
-		memoryRequestsPercentage: nm.memory.requestPercentageString(),
-		memoryLimits:             nm.memory.limitActualString(),
-		memoryLimitsPercentage:   nm.memory.limitPercentageString(),
-		memoryUtil:               nm.memory.utilActualString(),
-		memoryUtilPercentage:     nm.memory.utilPercentageString(),
-		podCountCurrent:          nm.podCount.podCountCurrentString(),
-		podCountAllocatable:      nm.podCount.podCountAllocatableString(),
+		node:                nodeName,
+		namespace:           VoidValue,
+		pod:                 VoidValue,
+		container:           VoidValue,
+		containerType:       VoidValue,
+		qosClass:            VoidValue,
+		priorityClass:       VoidValue,
+		resources:           cp.resourceLines(nm.resources),
+		replicas:            nm.podCount.podCountCurrentString(),
+		podCountCurrent:     nm.podCount.podCountCurrentString(),
+		podCountAllocatable: nm.podCount.podCountAllocatableString(),
+		reason:              VoidValue,
 	})
 }
 
-func (cp *csvPrinter) printPodLine(nodeName string, pm *podMetric) {
+// printClassLine prints the totals of a QoS or priority class of a node, or
+// of the cluster if nodeName is VoidValue.
+func (cp *csvPrinter) printClassLine(nodeName string, class *NodeMetric) {
+	cl := &csvLine{
+		node:                nodeName,
+		namespace:           VoidValue,
+		pod:                 VoidValue,
+		container:           VoidValue,
+		containerType:       VoidValue,
+		qosClass:            VoidValue,
+		priorityClass:       VoidValue,
+		resources:           cp.resourceLines(class.resources),
+		podCountCurrent:     class.podCount.podCountCurrentString(),
+		podCountAllocatable: class.podCount.podCountAllocatableString(),
+		reason:              VoidValue,
+	}
+	if cp.opts.GroupBy == PriorityGroup {
+		cl.priorityClass = class.name
+	} else {
+		cl.qosClass = class.name
+	}
+	cp.printLine(cl)
+}
+
+func (cp *csvPrinter) printPodLine(nodeName string, pm *PodMetric) {
 	cp.printLine(&csvLine{
-		node:                     nodeName,
-		namespace:                pm.namespace,
-		pod:                      pm.name,
-		container:                VoidValue,
-		cpuCapacity:              pm.cpu.capacityString(),
-		cpuRequests:              pm.cpu.requestActualString(),
-		cpuRequestsPercentage:    pm.cpu.requestPercentageString(),
-		cpuLimits:                pm.cpu.limitActualString(),
-		cpuLimitsPercentage:      pm.cpu.limitPercentageString(),
-		cpuUtil:                  pm.cpu.utilActualString(),
-		cpuUtilPercentage:        pm.cpu.utilPercentageString(),
-		memoryCapacity:           pm.memory.capacityString(),
-		memoryRequests:           pm.memory.requestActualString(),
-		memoryRequestsPercentage: pm.memory.requestPercentageString(),
-		memoryLimits:             pm.memory.limitActualString(),
-		memoryLimitsPercentage:   pm.memory.limitPercentageString(),
-		memoryUtil:               pm.memory.utilActualString(),
-		memoryUtilPercentage:     pm.memory.utilPercentageString(),
+		node:          nodeName,
+		namespace:     pm.namespace,
+		pod:           pm.name,
+		container:     VoidValue,
+		containerType: VoidValue,
+		qosClass:      noneIfEmpty(pm.qosClass),
+		priorityClass: noneIfEmpty(pm.priorityClass),
+		resources:     cp.resourceLines(pm.resources),
+		reason:        pm.pendingReason,
 	})
 }
 
-func (cp *csvPrinter) printContainerLine(nodeName string, pm *podMetric, cm *containerMetric) {
+func (cp *csvPrinter) printContainerLine(nodeName string, pm *PodMetric, cm *ContainerMetric) {
 	cp.printLine(&csvLine{
-		node:                     nodeName,
-		namespace:                pm.namespace,
-		pod:                      pm.name,
-		container:                cm.name,
-		cpuCapacity:              cm.cpu.capacityString(),
-		cpuRequests:              cm.cpu.requestActualString(),
-		cpuRequestsPercentage:    cm.cpu.requestPercentageString(),
-		cpuLimits:                cm.cpu.limitActualString(),
-		cpuLimitsPercentage:      cm.cpu.limitPercentageString(),
-		cpuUtil:                  cm.cpu.utilActualString(),
-		cpuUtilPercentage:        cm.cpu.utilPercentageString(),
-		memoryCapacity:           cm.memory.capacityString(),
-		memoryRequests:           cm.memory.requestActualString(),
-		memoryRequestsPercentage: cm.memory.requestPercentageString(),
-		memoryLimits:             cm.memory.limitActualString(),
-		memoryLimitsPercentage:   cm.memory.limitPercentageString(),
-		memoryUtil:               cm.memory.utilActualString(),
-		memoryUtilPercentage:     cm.memory.utilPercentageString(),
+		node:          nodeName,
+		namespace:     pm.namespace,
+		pod:           pm.name,
+		container:     cm.name,
+		containerType: cm.containerType,
+		qosClass:      noneIfEmpty(pm.qosClass),
+		priorityClass: noneIfEmpty(pm.priorityClass),
+		resources:     cp.resourceLines(cm.resources),
+		reason:        pm.pendingReason,
 	})
 }
@@ -0,0 +1,476 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	//DiffAdded is the status of an entry that only exists in the new snapshot
+	DiffAdded string = "added"
+	//DiffRemoved is the status of an entry that only exists in the old snapshot
+	DiffRemoved string = "removed"
+	//DiffChanged is the status of an entry whose resources have changed
+	DiffChanged string = "changed"
+	//DiffUnchanged is the status of an entry whose resources have not changed
+	DiffUnchanged string = "unchanged"
+)
+
+// diffEntry is the change in resources of the cluster, a node, a namespace
+// or a pod between two snapshots. Pod counts are nil for pods.
+type diffEntry struct {
+	level          string
+	name           string
+	status         string
+	oldResources   map[corev1.ResourceName]*ResourceMetric
+	newResources   map[corev1.ResourceName]*ResourceMetric
+	oldPodCount    *podCount
+	newPodCount    *podCount
+	hasPodCount    bool
+	resourceNames  []corev1.ResourceName
+	resourceFields []string
+}
+
+type listDiff struct {
+	Cluster    *listDiffEntry   `json:"cluster"`
+	Nodes      []*listDiffEntry `json:"nodes,omitempty"`
+	Namespaces []*listDiffEntry `json:"namespaces,omitempty"`
+	Pods       []*listDiffEntry `json:"pods,omitempty"`
+}
+
+type listDiffEntry struct {
+	Name      string                       `json:"name"`
+	Status    string                       `json:"status"`
+	CPU       *listResourceDiff            `json:"cpu,omitempty"`
+	Memory    *listResourceDiff            `json:"memory,omitempty"`
+	Resources map[string]*listResourceDiff `json:"resources,omitempty"`
+	PodCount  *listQuantityDiff            `json:"podCount,omitempty"`
+}
+
+type listResourceDiff struct {
+	Requests    *listQuantityDiff `json:"requests,omitempty"`
+	Limits      *listQuantityDiff `json:"limits,omitempty"`
+	Utilization *listQuantityDiff `json:"utilization,omitempty"`
+}
+
+type listQuantityDiff struct {
+	Old   string `json:"old"`
+	New   string `json:"new"`
+	Delta string `json:"delta"`
+}
+
+// DiffSnapshots outputs the change in requests, limits, utilization and pod
+// count of the cluster and each node, namespace and, if requested, pod
+// between two snapshots written by SaveSnapshot
+func DiffSnapshots(opts Options, oldFileName, newFileName string) error {
+	oldSnapshot, err := loadSnapshot(oldFileName)
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+	newSnapshot, err := loadSnapshot(newFileName)
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+
+	entries := buildDiff(oldSnapshot.clusterMetric(), newSnapshot.clusterMetric(), opts)
+	switch opts.OutputFormat {
+	case JSONOutput, YAMLOutput:
+		printDiffList(os.Stdout, entries, opts)
+	case CSVOutput, TSVOutput:
+		printDiffCSV(os.Stdout, entries, opts)
+	default:
+		printDiffTable(os.Stdout, entries, opts)
+	}
+	return nil
+}
+
+// buildDiff compares two cluster metrics. The cluster is always included,
+// while nodes, namespaces and pods are only included if they changed.
+func buildDiff(oldCM, newCM *ClusterMetric, opts Options) []*diffEntry {
+	entries := []*diffEntry{
+		newDiffEntry("cluster", VoidValue, oldCM.resources, newCM.resources, oldCM.podCount, newCM.podCount, true, opts),
+	}
+
+	addNodes := func(level string, oldNodes, newNodes map[string]*NodeMetric) {
+		for _, name := range unionKeys(oldNodes, newNodes) {
+			var oldResources, newResources map[corev1.ResourceName]*ResourceMetric
+			var oldPodCount, newPodCount *podCount
+			if nm := oldNodes[name]; nm != nil {
+				oldResources, oldPodCount = nm.resources, nm.podCount
+			}
+			if nm := newNodes[name]; nm != nil {
+				newResources, newPodCount = nm.resources, nm.podCount
+			}
+			entry := newDiffEntry(level, name, oldResources, newResources, oldPodCount, newPodCount, true, opts)
+			if entry.status != DiffUnchanged {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	addNodes("node", withUnscheduled(oldCM), withUnscheduled(newCM))
+	addNodes("namespace", oldCM.groupBy(NamespaceGroup).nodeMetrics, newCM.groupBy(NamespaceGroup).nodeMetrics)
+
+	if opts.ShowPods {
+		oldPods, newPods := podsByName(oldCM), podsByName(newCM)
+		for _, name := range unionKeys(oldPods, newPods) {
+			var oldResources, newResources map[corev1.ResourceName]*ResourceMetric
+			if pm := oldPods[name]; pm != nil {
+				oldResources = pm.resources
+			}
+			if pm := newPods[name]; pm != nil {
+				newResources = pm.resources
+			}
+			entry := newDiffEntry("pod", name, oldResources, newResources, nil, nil, false, opts)
+			if entry.status != DiffUnchanged {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	return entries
+}
+
+func newDiffEntry(level, name string, oldResources, newResources map[corev1.ResourceName]*ResourceMetric, oldPodCount, newPodCount *podCount, hasPodCount bool, opts Options) *diffEntry {
+	entry := &diffEntry{
+		level:          level,
+		name:           name,
+		oldResources:   oldResources,
+		newResources:   newResources,
+		oldPodCount:    oldPodCount,
+		newPodCount:    newPodCount,
+		hasPodCount:    hasPodCount,
+		resourceNames:  opts.resourceNames(),
+		resourceFields: diffResourceFields(opts),
+		status:         DiffUnchanged,
+	}
+
+	switch {
+	case oldResources == nil && level != "cluster":
+		entry.status = DiffAdded
+	case newResources == nil && level != "cluster":
+		entry.status = DiffRemoved
+	case entry.changed():
+		entry.status = DiffChanged
+	}
+
+	return entry
+}
+
+// diffResourceFields returns the resource fields compared by a diff.
+func diffResourceFields(opts Options) []string {
+	fields := []string{}
+	if !opts.HideRequests {
+		fields = append(fields, "request")
+	}
+	if !opts.HideLimits {
+		fields = append(fields, "limit")
+	}
+	if opts.ShowUtil {
+		fields = append(fields, "util")
+	}
+	return fields
+}
+
+func (de *diffEntry) changed() bool {
+	for _, name := range de.resourceNames {
+		for _, field := range de.resourceFields {
+			oldQ, newQ := de.quantities(name, field)
+			if oldQ.Cmp(newQ) != 0 {
+				return true
+			}
+		}
+	}
+
+	if de.hasPodCount {
+		oldCount, newCount := de.podCounts()
+		return oldCount != newCount
+	}
+	return false
+}
+
+// quantities returns the old and new values of a resource field, which are
+// zero for a missing side.
+func (de *diffEntry) quantities(name corev1.ResourceName, field string) (resource.Quantity, resource.Quantity) {
+	var oldQ, newQ resource.Quantity
+	if rm := de.oldResources[name]; rm != nil {
+		oldQ = rm.field(field)
+	}
+	if rm := de.newResources[name]; rm != nil {
+		newQ = rm.field(field)
+	}
+	return oldQ, newQ
+}
+
+func (de *diffEntry) podCounts() (int64, int64) {
+	var oldCount, newCount int64
+	if de.oldPodCount != nil {
+		oldCount = de.oldPodCount.current
+	}
+	if de.newPodCount != nil {
+		newCount = de.newPodCount.current
+	}
+	return oldCount, newCount
+}
+
+// diffValue returns a quantity in the unit it is displayed in, along with
+// that unit.
+func diffValue(resourceType string, q resource.Quantity) (int64, string) {
+	if resourceType == "cpu" {
+		return q.MilliValue(), "m"
+	}
+	if unit, unitBytes, isBytes := byteUnit(resourceType); isBytes {
+		return formatToUnit(q, unitBytes), unit
+	}
+	return q.Value(), ""
+}
+
+func (de *diffEntry) deltaString(name corev1.ResourceName, field string) string {
+	oldQ, newQ := de.quantities(name, field)
+	oldValue, unit := diffValue(string(name), oldQ)
+	newValue, _ := diffValue(string(name), newQ)
+	return fmt.Sprintf("%+d%s", newValue-oldValue, unit)
+}
+
+func (de *diffEntry) deltaCSVString(name corev1.ResourceName, field string) string {
+	oldQ, newQ := de.quantities(name, field)
+	oldValue, _ := diffValue(string(name), oldQ)
+	newValue, _ := diffValue(string(name), newQ)
+	return fmt.Sprintf("%d", newValue-oldValue)
+}
+
+func (de *diffEntry) podCountDeltaString() string {
+	if !de.hasPodCount {
+		return VoidValue
+	}
+	oldCount, newCount := de.podCounts()
+	return fmt.Sprintf("%+d", newCount-oldCount)
+}
+
+func (de *diffEntry) quantityDiff(name corev1.ResourceName, field string) *listQuantityDiff {
+	oldQ, newQ := de.quantities(name, field)
+	oldValue, unit := diffValue(string(name), oldQ)
+	newValue, _ := diffValue(string(name), newQ)
+	return &listQuantityDiff{
+		Old:   fmt.Sprintf("%d%s", oldValue, unit),
+		New:   fmt.Sprintf("%d%s", newValue, unit),
+		Delta: fmt.Sprintf("%+d%s", newValue-oldValue, unit),
+	}
+}
+
+// diffHeaders returns the headers of the resource columns of a diff.
+func diffHeaders(opts Options, withUnits bool) []string {
+	headers := []string{}
+	for _, name := range opts.resourceNames() {
+		unit := ""
+		if withUnits {
+			if u := resourceCSVUnit(string(name)); u != "" {
+				unit = fmt.Sprintf(" (%s)", u)
+			}
+		}
+		for _, field := range diffResourceFields(opts) {
+			header := map[string]string{"request": "REQUESTS", "limit": "LIMITS", "util": "UTIL"}[field]
+			headers = append(headers, fmt.Sprintf("%s %s%s", resourceDisplayName(name), header, unit))
+		}
+	}
+	return append(headers, "POD COUNT")
+}
+
+func printDiffTable(out io.Writer, entries []*diffEntry, opts Options) {
+	w := new(tabwriter.Writer)
+	w.Init(out, 0, 8, 2, ' ', 0)
+
+	headers := append([]string{"LEVEL", "NAME", "STATUS"}, diffHeaders(opts, false)...)
+	_, _ = fmt.Fprintln(w, strings.Join(headers, "\t "))
+
+	for _, de := range entries {
+		items := []string{de.level, de.name, de.status}
+		for _, name := range de.resourceNames {
+			for _, field := range de.resourceFields {
+				items = append(items, de.deltaString(name, field))
+			}
+		}
+		items = append(items, de.podCountDeltaString())
+		_, _ = fmt.Fprintln(w, strings.Join(items, "\t "))
+	}
+
+	err := w.Flush()
+	if err != nil {
+		fmt.Printf("Error writing to table: %s", err)
+	}
+}
+
+func printDiffCSV(out io.Writer, entries []*diffEntry, opts Options) {
+	separator := ","
+	if opts.OutputFormat == TSVOutput {
+		separator = "\t"
+	}
+	quote := func(s string) string {
+		return CSVStringTerminator + s + CSVStringTerminator
+	}
+
+	headers := []string{quote("LEVEL"), quote("NAME"), quote("STATUS")}
+	for _, header := range diffHeaders(opts, true) {
+		headers = append(headers, header+" DELTA")
+	}
+	_, _ = fmt.Fprintln(out, strings.Join(headers, separator))
+
+	for _, de := range entries {
+		items := []string{quote(de.level), quote(de.name), quote(de.status)}
+		for _, name := range de.resourceNames {
+			for _, field := range de.resourceFields {
+				items = append(items, de.deltaCSVString(name, field))
+			}
+		}
+		items = append(items, strings.TrimPrefix(de.podCountDeltaString(), "+"))
+		_, _ = fmt.Fprintln(out, strings.Join(items, separator))
+	}
+}
+
+func printDiffList(out io.Writer, entries []*diffEntry, opts Options) {
+	response := buildListDiff(entries, opts)
+
+	jsonRaw, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		fmt.Println("Error Marshalling JSON")
+		fmt.Println(err)
+		return
+	}
+
+	if opts.OutputFormat == JSONOutput {
+		_, _ = fmt.Fprintf(out, "%s", jsonRaw)
+		return
+	}
+
+	yamlRaw, err := yaml.JSONToYAML(jsonRaw)
+	if err != nil {
+		fmt.Println("Error Converting JSON to Yaml")
+		fmt.Println(err)
+		return
+	}
+	_, _ = fmt.Fprintf(out, "%s", yamlRaw)
+}
+
+func buildListDiff(entries []*diffEntry, opts Options) listDiff {
+	var response listDiff
+
+	for _, de := range entries {
+		lde := &listDiffEntry{
+			Name:   de.name,
+			Status: de.status,
+		}
+
+		for _, name := range de.resourceNames {
+			lrd := &listResourceDiff{}
+			for _, field := range de.resourceFields {
+				switch field {
+				case "request":
+					lrd.Requests = de.quantityDiff(name, field)
+				case "limit":
+					lrd.Limits = de.quantityDiff(name, field)
+				case "util":
+					lrd.Utilization = de.quantityDiff(name, field)
+				}
+			}
+
+			switch name {
+			case corev1.ResourceCPU:
+				lde.CPU = lrd
+			case corev1.ResourceMemory:
+				lde.Memory = lrd
+			default:
+				if lde.Resources == nil {
+					lde.Resources = map[string]*listResourceDiff{}
+				}
+				lde.Resources[string(name)] = lrd
+			}
+		}
+
+		if de.hasPodCount {
+			oldCount, newCount := de.podCounts()
+			lde.PodCount = &listQuantityDiff{
+				Old:   fmt.Sprintf("%d", oldCount),
+				New:   fmt.Sprintf("%d", newCount),
+				Delta: fmt.Sprintf("%+d", newCount-oldCount),
+			}
+		}
+
+		switch de.level {
+		case "cluster":
+			response.Cluster = lde
+		case "node":
+			response.Nodes = append(response.Nodes, lde)
+		case "namespace":
+			response.Namespaces = append(response.Namespaces, lde)
+		case "pod":
+			response.Pods = append(response.Pods, lde)
+		}
+	}
+
+	return response
+}
+
+// withUnscheduled returns the nodes of a cluster metric along with its
+// unscheduled pods, if any.
+func withUnscheduled(cm *ClusterMetric) map[string]*NodeMetric {
+	if cm.unscheduled == nil {
+		return cm.nodeMetrics
+	}
+
+	nodeMetrics := map[string]*NodeMetric{cm.unscheduled.name: cm.unscheduled}
+	for name, nm := range cm.nodeMetrics {
+		nodeMetrics[name] = nm
+	}
+	return nodeMetrics
+}
+
+// podsByName returns every pod of a cluster metric, keyed by
+// namespace/name.
+func podsByName(cm *ClusterMetric) map[string]*PodMetric {
+	pods := map[string]*PodMetric{}
+	for _, nm := range withUnscheduled(cm) {
+		for _, pm := range nm.podMetrics {
+			pods[fmt.Sprintf("%s/%s", pm.namespace, pm.name)] = pm
+		}
+	}
+	return pods
+}
+
+// unionKeys returns the sorted keys present in either map.
+func unionKeys[V any](m1, m2 map[string]V) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, m := range []map[string]V{m1, m2} {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,76 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestBuildDiff(t *testing.T) {
+	oldPodList := getGroupTestPodList()
+	oldCM := buildClusterMetric(oldPodList, nil, getGroupTestNodeList(), nil, defaultResourceNames)
+
+	// web-2 doubles its CPU request, dns is removed and cache is added.
+	newPodList := getGroupTestPodList()
+	newPodList.Items[1].Spec.Containers[0].Resources.Requests["cpu"] = resource.MustParse("800m")
+	cache := newPodList.Items[0].DeepCopy()
+	cache.Name = "cache"
+	newPodList.Items[2] = *cache
+	newCM := buildClusterMetric(newPodList, nil, getGroupTestNodeList(), nil, defaultResourceNames)
+
+	entries := buildDiff(&oldCM, &newCM, Options{ShowPods: true})
+
+	summary := []string{}
+	for _, de := range entries {
+		summary = append(summary, de.level+" "+de.name+" "+de.status)
+	}
+	assert.Equal(t, []string{
+		"cluster * changed",
+		"node node-1 changed",
+		"node node-2 changed",
+		"namespace default changed",
+		"namespace kube-system removed",
+		"pod default/cache added",
+		"pod default/web-2 changed",
+		"pod kube-system/dns removed",
+	}, summary)
+
+	cluster := entries[0]
+	assert.Equal(t, "+500m", cluster.deltaString("cpu", "request"))
+	assert.Equal(t, "-768Mi", cluster.deltaString("memory", "request"))
+	assert.Equal(t, "+0", cluster.podCountDeltaString())
+
+	dns := entries[7]
+	assert.Equal(t, "-100m", dns.deltaString("cpu", "request"))
+	assert.Equal(t, "-2048Mi", dns.deltaString("memory", "limit"))
+	assert.Equal(t, VoidValue, dns.podCountDeltaString())
+
+	var buf bytes.Buffer
+	printDiffCSV(&buf, entries[:1], Options{OutputFormat: CSVOutput})
+	assert.Equal(t, `"LEVEL","NAME","STATUS",CPU REQUESTS (milli) DELTA,CPU LIMITS (milli) DELTA,MEMORY REQUESTS (Mi) DELTA,MEMORY LIMITS (Mi) DELTA,POD COUNT DELTA
+"cluster","*","changed",500,200,-768,-1536,0
+`, buf.String())
+
+	response := buildListDiff(entries, Options{ShowPods: true})
+	assert.Equal(t, &listQuantityDiff{Old: "700m", New: "1200m", Delta: "+500m"}, response.Cluster.CPU.Requests)
+	assert.Len(t, response.Nodes, 2)
+	assert.Len(t, response.Namespaces, 2)
+	assert.Len(t, response.Pods, 3)
+}
@@ -0,0 +1,154 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	//KubernetesAPI is the name of the Kubernetes API in a ConnectionError
+	KubernetesAPI string = "Kubernetes"
+	//MetricsAPI is the name of the Metrics API in a ConnectionError
+	MetricsAPI string = "Metrics API"
+	//PrometheusAPI is the name of the Prometheus HTTP API in a ConnectionError
+	PrometheusAPI string = "Prometheus"
+)
+
+// ErrNoVisibleColumns is returned when output has been requested without
+// any of requests, limits, utilization or pod count
+var ErrNoVisibleColumns = errors.New("no data columns selected for display")
+
+// ConnectionError is returned when a client for the Kubernetes API, the
+// Metrics API or Prometheus can't be created
+type ConnectionError struct {
+	// API is KubernetesAPI, MetricsAPI or PrometheusAPI.
+	API string
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("connecting to %s: %v", e.API, e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// ListError is returned when Kubernetes resources can't be listed
+type ListError struct {
+	// Resource is the kind of resource that was listed, e.g. "Nodes".
+	Resource string
+	Err      error
+}
+
+func (e *ListError) Error() string {
+	return fmt.Sprintf("listing %s: %v", e.Resource, e.Err)
+}
+
+func (e *ListError) Unwrap() error {
+	return e.Err
+}
+
+// MetricsError is returned when metrics can't be retrieved from the Metrics
+// API, usually because metrics-server is not running
+type MetricsError struct {
+	// Resource is "Pod" or "Node".
+	Resource string
+	Err      error
+}
+
+func (e *MetricsError) Error() string {
+	return fmt.Sprintf("getting %s Metrics: %v", e.Resource, e.Err)
+}
+
+func (e *MetricsError) Unwrap() error {
+	return e.Err
+}
+
+// SummaryError is returned when utilization can't be retrieved from the
+// kubelet Summary API of a node
+type SummaryError struct {
+	Node string
+	Err  error
+}
+
+func (e *SummaryError) Error() string {
+	return fmt.Sprintf("getting Summary from kubelet on node %s: %v", e.Node, e.Err)
+}
+
+func (e *SummaryError) Unwrap() error {
+	return e.Err
+}
+
+// PrometheusError is returned when a query for utilization fails or returns
+// an unexpected result
+type PrometheusError struct {
+	Query string
+	Err   error
+}
+
+func (e *PrometheusError) Error() string {
+	return fmt.Sprintf("querying Prometheus: %v", e.Err)
+}
+
+func (e *PrometheusError) Unwrap() error {
+	return e.Err
+}
+
+// OptionError is returned when an option can't be parsed
+type OptionError struct {
+	// Option is the option that could not be parsed, e.g. "taint".
+	Option string
+	Err    error
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("parsing %s parameter: %v", e.Option, e.Err)
+}
+
+func (e *OptionError) Unwrap() error {
+	return e.Err
+}
+
+// ThresholdError is returned when resource data meets any of the
+// thresholds it was checked against
+type ThresholdError struct {
+	// Failed holds each threshold that was met by at least one row.
+	Failed []string
+	// Checks is the number of thresholds that were checked.
+	Checks int
+}
+
+func (e *ThresholdError) Error() string {
+	return fmt.Sprintf("%d of %d checks failed: %s", len(e.Failed), e.Checks, strings.Join(e.Failed, ", "))
+}
+
+// ContextError is returned for each kubeconfig context that resource data
+// could not be gathered from when gathering data from several contexts
+type ContextError struct {
+	Context string
+	Err     error
+}
+
+func (e *ContextError) Error() string {
+	return fmt.Sprintf("gathering data from context %s: %v", e.Context, e.Err)
+}
+
+func (e *ContextError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,109 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// fetchGroup runs requests to the API concurrently, cancelling those still
+// running when one fails, and records how long each took.
+type fetchGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	calls  []*fetchCall
+	failed bool
+}
+
+// fetchCall is a request run by a fetchGroup.
+type fetchCall struct {
+	name     string
+	duration time.Duration
+	err      error
+	// afterFailure is set when the call failed after another call had
+	// already failed, most likely because it was cancelled as a result.
+	afterFailure bool
+}
+
+func newFetchGroup(ctx context.Context) *fetchGroup {
+	ctx, cancel := context.WithCancel(ctx)
+	return &fetchGroup{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a new goroutine, recording how long it took under name.
+func (g *fetchGroup) Go(name string, fn func(ctx context.Context) error) {
+	call := &fetchCall{name: name}
+	g.mu.Lock()
+	g.calls = append(g.calls, call)
+	g.mu.Unlock()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		start := time.Now()
+		err := fn(g.ctx)
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		call.duration = time.Since(start)
+		if err != nil {
+			call.err = err
+			call.afterFailure = g.failed
+			g.failed = true
+			g.cancel()
+		}
+	}()
+}
+
+// Wait waits for every call started so far to return. It returns the error
+// of the call that failed first, rather than the cancellation it caused in
+// other calls, or nil if none failed. More calls may be started once Wait
+// has returned.
+func (g *fetchGroup) Wait() error {
+	g.wg.Wait()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, call := range g.calls {
+		if call.err != nil && !call.afterFailure {
+			return call.err
+		}
+	}
+	return nil
+}
+
+// printTimings writes how long each call took, in the order they were
+// started, followed by the total time taken.
+func (g *fetchGroup) printTimings(out io.Writer, title string, total time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, _ = fmt.Fprintln(out, title)
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	for _, call := range g.calls {
+		_, _ = fmt.Fprintf(w, "  %s\t%s\n", call.name, call.duration.Round(time.Millisecond))
+	}
+	_, _ = fmt.Fprintf(w, "  total\t%s\n", total.Round(time.Millisecond))
+	_ = w.Flush()
+}
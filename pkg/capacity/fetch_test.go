@@ -0,0 +1,66 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchGroup(t *testing.T) {
+	g := newFetchGroup(context.TODO())
+	g.Go("nodes", func(ctx context.Context) error {
+		// Fails only once cancelled by the failure of pod metrics.
+		<-ctx.Done()
+		return errors.New("listing Nodes: context canceled")
+	})
+	g.Go("pod metrics", func(ctx context.Context) error {
+		return errors.New("getting Pod Metrics: service unavailable")
+	})
+	g.Go("namespaces", func(ctx context.Context) error {
+		return nil
+	})
+	assert.EqualError(t, g.Wait(), "getting Pod Metrics: service unavailable")
+
+	g = newFetchGroup(context.TODO())
+	var nodes, pods bool
+	g.Go("nodes", func(ctx context.Context) error {
+		nodes = true
+		return nil
+	})
+	assert.NoError(t, g.Wait())
+	g.Go("pods", func(ctx context.Context) error {
+		pods = true
+		return nil
+	})
+	assert.NoError(t, g.Wait())
+	assert.True(t, nodes)
+	assert.True(t, pods)
+
+	var buf bytes.Buffer
+	g.printTimings(&buf, "Fetched data in 5ms:", 5*time.Millisecond)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.Equal(t, "Fetched data in 5ms:", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "  nodes  "))
+	assert.True(t, strings.HasPrefix(lines[2], "  pods   "))
+	assert.Equal(t, "  total  5ms", lines[3])
+}
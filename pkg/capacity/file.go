@@ -0,0 +1,168 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	v1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metrics "k8s.io/metrics/pkg/client/clientset/versioned"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+// StdinFileName is the file name that reads objects from standard input
+const StdinFileName = "-"
+
+// fileScheme contains the types that can be read from files.
+var fileScheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(fileScheme))
+	utilruntime.Must(v1beta1.AddToScheme(fileScheme))
+}
+
+// newFileClientSets returns clients that serve the objects read from files
+// instead of a cluster, so the same filters apply as when listing from the
+// API. Files may contain JSON or YAML objects or lists, including
+// PodMetricsList and NodeMetricsList. Objects of kinds kube-capacity does not
+// know are ignored, and objects that appear more than once are taken from
+// the last file they appear in.
+func newFileClientSets(fileNames []string) (kubernetes.Interface, metrics.Interface, error) {
+	objects := map[string]runtime.Object{}
+	keys := []string{}
+
+	for _, fileName := range fileNames {
+		fileObjects, err := readObjectsFromFile(fileName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading %s: %w", fileName, err)
+		}
+
+		for _, obj := range fileObjects {
+			accessor, err := meta.Accessor(obj)
+			if err != nil {
+				return nil, nil, fmt.Errorf("reading %s: %w", fileName, err)
+			}
+			key := fmt.Sprintf("%T/%s/%s", obj, accessor.GetNamespace(), accessor.GetName())
+			if _, found := objects[key]; !found {
+				keys = append(keys, key)
+			}
+			objects[key] = obj
+		}
+	}
+
+	clientset := fake.NewSimpleClientset()
+	mClientset := metricsfake.NewSimpleClientset()
+	for _, key := range keys {
+		var err error
+		switch obj := objects[key].(type) {
+		// The Metrics API serves metrics as "pods" and "nodes", which
+		// can't be guessed from their kinds.
+		case *v1beta1.PodMetrics:
+			err = mClientset.Tracker().Create(v1beta1.SchemeGroupVersion.WithResource("pods"), obj, obj.Namespace)
+		case *v1beta1.NodeMetrics:
+			err = mClientset.Tracker().Create(v1beta1.SchemeGroupVersion.WithResource("nodes"), obj, "")
+		default:
+			err = clientset.Tracker().Add(obj)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return clientset, mClientset, nil
+}
+
+// readObjectsFromFile returns the objects in a file, with lists expanded
+// into their items.
+func readObjectsFromFile(fileName string) ([]runtime.Object, error) {
+	var r io.Reader = os.Stdin
+	if fileName != StdinFileName {
+		f, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	objects := []runtime.Object{}
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var raw runtime.RawExtension
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, err
+		}
+		if len(raw.Raw) == 0 {
+			continue
+		}
+
+		decoded, err := decodeObjects(raw.Raw)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+}
+
+// decodeObjects decodes a JSON or YAML object, expanding lists into their
+// items.
+func decodeObjects(data []byte) ([]runtime.Object, error) {
+	obj, _, err := serializer.NewCodecFactory(fileScheme).UniversalDeserializer().Decode(data, nil, nil)
+	if err != nil {
+		if runtime.IsNotRegisteredError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !meta.IsListType(obj) {
+		return []runtime.Object{obj}, nil
+	}
+
+	items, err := meta.ExtractList(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []runtime.Object{}
+	for _, item := range items {
+		// Items of generic lists, such as those printed by kubectl
+		// get pods,nodes, are left undecoded.
+		if unknown, ok := item.(*runtime.Unknown); ok {
+			decoded, err := decodeObjects(unknown.Raw)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, decoded...)
+			continue
+		}
+		objects = append(objects, item)
+	}
+	return objects, nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const testFileList = `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {"apiVersion": "v1", "kind": "Node", "metadata": {"name": "node-1", "labels": {"pool": "a"}},
+     "status": {"allocatable": {"cpu": "2", "memory": "4Gi", "pods": "110"}}},
+    {"apiVersion": "v1", "kind": "Node", "metadata": {"name": "node-2", "labels": {"pool": "b"}},
+     "status": {"allocatable": {"cpu": "4", "memory": "8Gi", "pods": "110"}}},
+    {"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "web-1", "namespace": "default", "labels": {"app": "web"}},
+     "spec": {"nodeName": "node-1", "containers": [{"name": "app", "resources": {"requests": {"cpu": "250m"}}}]}},
+    {"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web", "namespace": "default"}},
+    {"apiVersion": "example.com/v1", "kind": "Widget", "metadata": {"name": "unknown"}}
+  ]
+}`
+
+const testFileMetrics = `apiVersion: metrics.k8s.io/v1beta1
+kind: PodMetricsList
+items:
+- metadata:
+    name: web-1
+    namespace: default
+  containers:
+  - name: app
+    usage:
+      cpu: 100m
+      memory: 64Mi
+---
+apiVersion: v1
+kind: PodList
+items:
+- metadata:
+    name: db-1
+    namespace: data
+    labels:
+      app: db
+  spec:
+    nodeName: node-2
+    containers:
+    - name: db
+      resources:
+        requests:
+          cpu: "1"
+`
+
+func TestNewFileClientSets(t *testing.T) {
+	dir := t.TempDir()
+	listFile := filepath.Join(dir, "list.json")
+	metricsFile := filepath.Join(dir, "metrics.yaml")
+	assert.NoError(t, os.WriteFile(listFile, []byte(testFileList), 0o600))
+	assert.NoError(t, os.WriteFile(metricsFile, []byte(testFileMetrics), 0o600))
+
+	// Objects repeated across files are only added once.
+	clientset, mClientset, err := newFileClientSets([]string{listFile, metricsFile, listFile})
+	assert.NoError(t, err)
+
+	opts := Options{ShowUtil: true, Namespace: "default"}
+	cm, err := collectClusterMetric(context.TODO(), clientset, mClientset, opts, false)
+	assert.NoError(t, err)
+	assert.Len(t, cm.nodeMetrics, 2)
+	pm := cm.nodeMetrics["node-1"].podMetrics["default-web-1"]
+	assert.NotNil(t, pm)
+	ensureEqualResourceMetric(t, pm.resources[corev1.ResourceCPU], &ResourceMetric{
+		allocatable: resource.MustParse("2"),
+		request:     resource.MustParse("250m"),
+		utilization: resource.MustParse("100m"),
+	})
+
+	opts = Options{PodLabels: "app=db", NodeLabels: "pool=b"}
+	cm, err = collectClusterMetric(context.TODO(), clientset, mClientset, opts, false)
+	assert.NoError(t, err)
+	assert.Len(t, cm.nodeMetrics, 1)
+	assert.Len(t, cm.nodeMetrics["node-2"].podMetrics, 1)
+	assert.NotNil(t, cm.nodeMetrics["node-2"].podMetrics["data-db-1"])
+
+	_, _, err = newFileClientSets([]string{filepath.Join(dir, "missing.json")})
+	assert.Error(t, err)
+
+	invalidFile := filepath.Join(dir, "invalid.yaml")
+	assert.NoError(t, os.WriteFile(invalidFile, []byte("kind: ["), 0o600))
+	_, _, err = newFileClientSets([]string{invalidFile})
+	assert.Error(t, err)
+}
@@ -0,0 +1,346 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	//NodeGroup is the constant value for grouping output by node
+	NodeGroup string = "node"
+	//NamespaceGroup is the constant value for grouping output by namespace
+	NamespaceGroup string = "namespace"
+	//WorkloadGroup is the constant value for grouping output by owning workload
+	WorkloadGroup string = "workload"
+	//QOSGroup is the constant value for grouping output by pod QoS class
+	QOSGroup string = "qos"
+	//PriorityGroup is the constant value for grouping output by pod priority class
+	PriorityGroup string = "priority"
+)
+
+// maxOwnerDepth limits how many levels of owners are followed when
+// resolving the workload a pod belongs to.
+const maxOwnerDepth = 5
+
+// workloadRef identifies the controller that owns a pod, or the pod itself
+// if it has no controller.
+type workloadRef struct {
+	kind string
+	name string
+}
+
+// String returns the kind and name of the workload, e.g. "Deployment/web".
+func (w workloadRef) String() string {
+	return fmt.Sprintf("%s/%s", w.kind, w.name)
+}
+
+// podWorkload returns a reference to the direct controller of a pod.
+func podWorkload(pod *corev1.Pod) workloadRef {
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		return workloadRef{kind: owner.Kind, name: owner.Name}
+	}
+	return workloadRef{kind: "Pod", name: pod.Name}
+}
+
+// workloadOwnerKey returns the key used to look up the owner of a
+// namespaced object in the map passed to resolveWorkloads.
+func workloadOwnerKey(namespace string, ref workloadRef) string {
+	return fmt.Sprintf("%s/%s/%s", namespace, ref.kind, ref.name)
+}
+
+// SupportedGroupBys returns a string list of groupings supported by this package
+func SupportedGroupBys() []string {
+	return []string{
+		NodeGroup,
+		NamespaceGroup,
+		WorkloadGroup,
+		QOSGroup,
+		PriorityGroup,
+	}
+}
+
+// groupBy returns a view of the cluster metrics in which nodes are replaced
+// by the requested grouping. Grouping by node returns cm unchanged.
+func (cm *ClusterMetric) groupBy(groupBy string) *ClusterMetric {
+	switch groupBy {
+	case NamespaceGroup:
+		return cm.groupPods(func(pm *PodMetric) string {
+			return pm.namespace
+		})
+	case WorkloadGroup:
+		return cm.groupPods(func(pm *PodMetric) string {
+			return fmt.Sprintf("%s/%s/%s", pm.namespace, pm.workload.kind, pm.workload.name)
+		})
+	case QOSGroup:
+		return cm.groupByClass(func(pm *PodMetric) string {
+			return pm.qosClass
+		})
+	case PriorityGroup:
+		return cm.groupByClass(func(pm *PodMetric) string {
+			return pm.priorityClass
+		})
+	default:
+		return cm
+	}
+}
+
+// resolveWorkloads walks up the owners of each pod's controller, so pods
+// owned by a ReplicaSet are attributed to its Deployment and pods owned by
+// a Job are attributed to its CronJob. owners maps workloadOwnerKey to the
+// controller of that object.
+func (cm *ClusterMetric) resolveWorkloads(owners map[string]workloadRef) {
+	resolve := func(pm *PodMetric) {
+		for i := 0; i < maxOwnerDepth; i++ {
+			owner, ok := owners[workloadOwnerKey(pm.namespace, pm.workload)]
+			if !ok {
+				return
+			}
+			pm.workload = owner
+		}
+	}
+
+	for _, nm := range cm.nodeMetrics {
+		for _, pm := range nm.podMetrics {
+			resolve(pm)
+		}
+	}
+	if cm.unscheduled != nil {
+		for _, pm := range cm.unscheduled.podMetrics {
+			resolve(pm)
+		}
+	}
+}
+
+// groupNodesByLabel returns a view of the cluster metrics in which nodes are
+// aggregated into one bucket per value of the given label. Nodes without
+// the label are collected into a "<none>" bucket.
+func (cm *ClusterMetric) groupNodesByLabel(labelKey string) *ClusterMetric {
+	grouped := &ClusterMetric{
+		resources:   cm.resources,
+		nodeMetrics: map[string]*NodeMetric{},
+		unscheduled: cm.unscheduled,
+		podCount:    cm.podCount,
+	}
+
+	for _, nm := range cm.nodeMetrics {
+		name, ok := nm.labels[labelKey]
+		if !ok {
+			name = NoneGroupName
+		}
+
+		group := grouped.nodeMetrics[name]
+		if group == nil {
+			group = &NodeMetric{
+				name:       name,
+				resources:  map[corev1.ResourceName]*ResourceMetric{},
+				podMetrics: map[string]*PodMetric{},
+				podCount:   &podCount{},
+			}
+			for resourceName, rm := range cm.resources {
+				group.resources[resourceName] = &ResourceMetric{
+					resourceType: rm.resourceType,
+				}
+			}
+			grouped.nodeMetrics[name] = group
+		}
+
+		for resourceName, rm := range group.resources {
+			rm.addMetric(nm.resources[resourceName])
+		}
+		for podKey, pm := range nm.podMetrics {
+			group.podMetrics[podKey] = pm
+		}
+		group.podCount.current += nm.podCount.current
+		group.podCount.allocatable += nm.podCount.allocatable
+	}
+
+	return grouped
+}
+
+// groupPods returns a view of the cluster metrics in which scheduled pods
+// are bucketed by key instead of by node. The allocatable resources of
+// each bucket, and of the pods in it, are those of the whole cluster, so
+// percentages represent their share of the cluster. Unscheduled pods are
+// left in their own section.
+func (cm *ClusterMetric) groupPods(key func(pm *PodMetric) string) *ClusterMetric {
+	grouped := &ClusterMetric{
+		resources:   cm.resources,
+		nodeMetrics: map[string]*NodeMetric{},
+		unscheduled: cm.unscheduled,
+		podCount:    cm.podCount,
+	}
+
+	for _, nm := range cm.nodeMetrics {
+		for podKey, pm := range nm.podMetrics {
+			name := key(pm)
+			group := grouped.nodeMetrics[name]
+			if group == nil {
+				group = newPodGroup(name, cm.resources, cm.podCount.allocatable)
+				grouped.nodeMetrics[name] = group
+			}
+			group.addPod(podKey, pm.shareOf(cm.resources))
+		}
+	}
+
+	return grouped
+}
+
+// groupByClass returns a view of the cluster metrics in which the pods of
+// the cluster and of each node are also grouped by the class returned by
+// key, e.g. their QoS class. The allocatable resources of each class are
+// those of the node or cluster it belongs to, so percentages represent its
+// share of them. Pods without a class are grouped under NoneGroupName.
+func (cm *ClusterMetric) groupByClass(key func(pm *PodMetric) string) *ClusterMetric {
+	grouped := &ClusterMetric{
+		resources:   cm.resources,
+		nodeMetrics: map[string]*NodeMetric{},
+		podCount:    cm.podCount,
+		classes:     map[string]*NodeMetric{},
+	}
+
+	classify := func(nm *NodeMetric, scheduled bool) *NodeMetric {
+		classified := *nm
+		classified.classes = map[string]*NodeMetric{}
+		for podKey, pm := range nm.podMetrics {
+			name := noneIfEmpty(key(pm))
+			if classified.classes[name] == nil {
+				classified.classes[name] = newPodGroup(name, nm.resources, nm.podCount.allocatable)
+			}
+			classified.classes[name].addPod(podKey, pm)
+
+			if scheduled {
+				if grouped.classes[name] == nil {
+					grouped.classes[name] = newPodGroup(name, cm.resources, cm.podCount.allocatable)
+				}
+				grouped.classes[name].addPod(podKey, pm)
+			}
+		}
+		return &classified
+	}
+
+	for name, nm := range cm.nodeMetrics {
+		grouped.nodeMetrics[name] = classify(nm, true)
+	}
+	if cm.unscheduled != nil {
+		grouped.unscheduled = classify(cm.unscheduled, false)
+	}
+
+	return grouped
+}
+
+// newPodGroup returns an empty group of pods whose allocatable resources
+// and pods are those of the given resources and pod count.
+func newPodGroup(name string, resources map[corev1.ResourceName]*ResourceMetric, allocatablePods int64) *NodeMetric {
+	group := &NodeMetric{
+		name:       name,
+		resources:  map[corev1.ResourceName]*ResourceMetric{},
+		podMetrics: map[string]*PodMetric{},
+		podCount: &podCount{
+			allocatable: allocatablePods,
+		},
+	}
+	for resourceName, rm := range resources {
+		group.resources[resourceName] = &ResourceMetric{
+			resourceType: rm.resourceType,
+			allocatable:  rm.allocatable,
+		}
+	}
+	return group
+}
+
+// addPod adds a pod and its resources to a group created by newPodGroup.
+func (nm *NodeMetric) addPod(podKey string, pm *PodMetric) {
+	nm.podMetrics[podKey] = pm
+	nm.podCount.current++
+	for resourceName, rm := range nm.resources {
+		rm.request.Add(pm.resources[resourceName].request)
+		rm.limit.Add(pm.resources[resourceName].limit)
+		rm.utilization.Add(pm.resources[resourceName].utilization)
+		rm.addSamples(pm.resources[resourceName].samples)
+	}
+}
+
+// shareOf returns a copy of the pod in which the allocatable resources of
+// the pod and its containers are those of allocatable rather than those of
+// its node.
+func (pm *PodMetric) shareOf(allocatable map[corev1.ResourceName]*ResourceMetric) *PodMetric {
+	share := *pm
+	share.resources = resourcesShareOf(pm.resources, allocatable)
+	share.containerMetrics = map[string]*ContainerMetric{}
+	for name, ctm := range pm.containerMetrics {
+		containerShare := *ctm
+		containerShare.resources = resourcesShareOf(ctm.resources, allocatable)
+		share.containerMetrics[name] = &containerShare
+	}
+	return &share
+}
+
+func resourcesShareOf(resources, allocatable map[corev1.ResourceName]*ResourceMetric) map[corev1.ResourceName]*ResourceMetric {
+	share := make(map[corev1.ResourceName]*ResourceMetric, len(resources))
+	for name, rm := range resources {
+		rmShare := *rm
+		if a := allocatable[name]; a != nil {
+			rmShare.allocatable = a.allocatable
+		}
+		share[name] = &rmShare
+	}
+	return share
+}
+
+// getSortedClassMetrics returns the classes of a node or cluster grouped by
+// groupByClass, sorted as nodes are.
+func getSortedClassMetrics(classes map[string]*NodeMetric, sortBy string) []*NodeMetric {
+	view := &ClusterMetric{nodeMetrics: classes}
+	return view.getSortedNodeMetrics(sortBy)
+}
+
+// noneIfEmpty returns NoneGroupName in place of an empty class.
+func noneIfEmpty(class string) string {
+	if class == "" {
+		return NoneGroupName
+	}
+	return class
+}
+
+// group returns a view of the cluster metrics grouped as requested by
+// either --group-nodes-by or --group-by.
+func (cm *ClusterMetric) group(opts Options) *ClusterMetric {
+	if opts.GroupNodesBy != "" {
+		return cm.groupNodesByLabel(opts.GroupNodesBy)
+	}
+	return cm.groupBy(opts.GroupBy)
+}
+
+// groupHeader returns the header used for the first column of output,
+// which names the node or group each row belongs to.
+func groupHeader(opts Options) string {
+	if opts.GroupNodesBy != "" {
+		return strings.ToUpper(opts.GroupNodesBy)
+	}
+
+	switch opts.GroupBy {
+	case NamespaceGroup:
+		return "NAMESPACE"
+	case WorkloadGroup:
+		return "WORKLOAD"
+	default:
+		return "NODE"
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGroupByNamespace(t *testing.T) {
+	cm := buildClusterMetric(getGroupTestPodList(), nil, getGroupTestNodeList(), nil, defaultResourceNames)
+
+	grouped := cm.groupBy(NamespaceGroup)
+
+	assert.Equal(t, cm.resources, grouped.resources)
+	assert.Len(t, grouped.nodeMetrics, 2)
+
+	defaultNs := grouped.nodeMetrics["default"]
+	assert.NotNil(t, defaultNs)
+	assert.Len(t, defaultNs.podMetrics, 2)
+	assert.Equal(t, int64(2), defaultNs.podCount.current)
+	assert.Equal(t, int64(220), defaultNs.podCount.allocatable)
+	ensureEqualResourceMetric(t, defaultNs.resources[corev1.ResourceCPU], &ResourceMetric{
+		allocatable: resource.MustParse("4"),
+		request:     resource.MustParse("600m"),
+		limit:       resource.MustParse("1200m"),
+	})
+	assert.Equal(t, "600m (15%%)", defaultNs.resources[corev1.ResourceCPU].requestString(false))
+
+	// Pods are a share of the cluster, like the namespace they are grouped
+	// into, without changing the pods of the ungrouped view.
+	web1 := defaultNs.podMetrics["default-web-1"]
+	assert.Equal(t, "200m (5%%)", web1.resources[corev1.ResourceCPU].requestString(false))
+	assert.Equal(t, "200m (5%%)", web1.containerMetrics["app"].resources[corev1.ResourceCPU].requestString(false))
+	assert.Equal(t, "200m (10%%)", cm.nodeMetrics["node-1"].podMetrics["default-web-1"].resources[corev1.ResourceCPU].requestString(false))
+
+	kubeSystem := grouped.nodeMetrics["kube-system"]
+	assert.NotNil(t, kubeSystem)
+	assert.Equal(t, int64(1), kubeSystem.podCount.current)
+	ensureEqualResourceMetric(t, kubeSystem.resources[corev1.ResourceMemory], &ResourceMetric{
+		allocatable: resource.MustParse("8Gi"),
+		request:     resource.MustParse("1Gi"),
+		limit:       resource.MustParse("2Gi"),
+	})
+
+	sorted := grouped.getSortedNodeMetrics("cpu.request")
+	assert.Equal(t, "default", sorted[0].name)
+	assert.Equal(t, "kube-system", sorted[1].name)
+
+	assert.Same(t, &cm, cm.groupBy(NodeGroup))
+}
+
+func TestGroupNodesByLabel(t *testing.T) {
+	nodeList := getGroupTestNodeList()
+	nodeList.Items = append(nodeList.Items, corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-3",
+		},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				"cpu":    resource.MustParse("8"),
+				"memory": resource.MustParse("16Gi"),
+				"pods":   resource.MustParse("50"),
+			},
+		},
+	})
+	nodeList.Items[1].Labels["topology.kubernetes.io/zone"] = "zone-a"
+
+	cm := buildClusterMetric(getGroupTestPodList(), nil, nodeList, nil, defaultResourceNames)
+
+	grouped := cm.group(Options{GroupNodesBy: "topology.kubernetes.io/zone"})
+	assert.Len(t, grouped.nodeMetrics, 2)
+
+	zoneA := grouped.nodeMetrics["zone-a"]
+	assert.NotNil(t, zoneA)
+	assert.Len(t, zoneA.podMetrics, 3)
+	assert.Equal(t, int64(3), zoneA.podCount.current)
+	assert.Equal(t, int64(220), zoneA.podCount.allocatable)
+	ensureEqualResourceMetric(t, zoneA.resources[corev1.ResourceCPU], &ResourceMetric{
+		allocatable: resource.MustParse("4"),
+		request:     resource.MustParse("700m"),
+		limit:       resource.MustParse("1400m"),
+	})
+
+	none := grouped.nodeMetrics[NoneGroupName]
+	assert.NotNil(t, none)
+	assert.Len(t, none.podMetrics, 0)
+	assert.Equal(t, int64(50), none.podCount.allocatable)
+	ensureEqualResourceMetric(t, none.resources[corev1.ResourceCPU], &ResourceMetric{
+		allocatable: resource.MustParse("8"),
+	})
+
+	sorted := grouped.getSortedNodeMetrics("cpu.request.percentage")
+	assert.Equal(t, "zone-a", sorted[0].name)
+	assert.Equal(t, NoneGroupName, sorted[1].name)
+
+	sorted = grouped.getSortedNodeMetrics("pod.count")
+	assert.Equal(t, "zone-a", sorted[0].name)
+
+	assert.Equal(t, "TOPOLOGY.KUBERNETES.IO/ZONE", groupHeader(Options{GroupNodesBy: "topology.kubernetes.io/zone"}))
+}
+
+func TestBuildListClusterMetricsGroupByNamespace(t *testing.T) {
+	cm := buildClusterMetric(getGroupTestPodList(), nil, getGroupTestNodeList(), nil, defaultResourceNames)
+
+	lp := listPrinter{
+		cm: cm.groupBy(NamespaceGroup),
+		opts: Options{
+			GroupBy:      NamespaceGroup,
+			ShowPodCount: true,
+		},
+	}
+
+	lcm := lp.buildListClusterMetrics()
+
+	assert.Nil(t, lcm.Nodes)
+	assert.Len(t, lcm.Namespaces, 2)
+	assert.Equal(t, "default", lcm.Namespaces[0].Name)
+	assert.Equal(t, "2/220", lcm.Namespaces[0].PodCount)
+	assert.Equal(t, "kube-system", lcm.Namespaces[1].Name)
+	assert.Equal(t, "1/220", lcm.Namespaces[1].PodCount)
+}
+
+func TestGroupByWorkload(t *testing.T) {
+	podList := getGroupTestPodList()
+	controller := true
+	podList.Items[0].OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-7c9f8d", Controller: &controller}}
+	podList.Items[1].OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-7c9f8d", Controller: &controller}}
+
+	cm := buildClusterMetric(podList, nil, getGroupTestNodeList(), nil, defaultResourceNames)
+	cm.resolveWorkloads(map[string]workloadRef{
+		"default/ReplicaSet/web-7c9f8d": {kind: "Deployment", name: "web"},
+	})
+
+	grouped := cm.groupBy(WorkloadGroup)
+	assert.Len(t, grouped.nodeMetrics, 2)
+
+	web := grouped.nodeMetrics["default/Deployment/web"]
+	assert.NotNil(t, web)
+	assert.Len(t, web.podMetrics, 2)
+	assert.Equal(t, int64(2), web.podCount.current)
+	ensureEqualResourceMetric(t, web.resources[corev1.ResourceCPU], &ResourceMetric{
+		allocatable: resource.MustParse("4"),
+		request:     resource.MustParse("600m"),
+		limit:       resource.MustParse("1200m"),
+	})
+
+	dns := grouped.nodeMetrics["kube-system/Pod/dns"]
+	assert.NotNil(t, dns)
+	assert.Equal(t, int64(1), dns.podCount.current)
+
+	assert.Equal(t, "WORKLOAD", groupHeader(Options{GroupBy: WorkloadGroup}))
+
+	lp := listPrinter{cm: grouped, opts: Options{GroupBy: WorkloadGroup}}
+	lcm := lp.buildListClusterMetrics()
+	assert.Equal(t, "default/Deployment/web", lcm.Workloads[0].Name)
+	assert.Equal(t, int64(2), lcm.Workloads[0].Replicas)
+	assert.Equal(t, int64(1), lcm.Workloads[1].Replicas)
+
+	var buf bytes.Buffer
+	cp := &csvPrinter{cm: grouped, file: &buf, opts: Options{GroupBy: WorkloadGroup}}
+	cp.Print(CSVOutput)
+	lines := strings.Split(buf.String(), "\n")
+	assert.True(t, strings.HasPrefix(lines[0], `"WORKLOAD",REPLICAS,`))
+	assert.True(t, strings.HasPrefix(lines[1], `"*",*,`))
+	assert.True(t, strings.HasPrefix(lines[2], `"default/Deployment/web",2,`))
+}
+
+func TestGroupByClass(t *testing.T) {
+	podList := getGroupTestPodList()
+	podList.Items[0].Status.QOSClass = corev1.PodQOSBurstable
+	podList.Items[1].Status.QOSClass = corev1.PodQOSBurstable
+	podList.Items[2].Spec.PriorityClassName = "system-cluster-critical"
+	pending := podList.Items[0].DeepCopy()
+	pending.Name = "web-3"
+	pending.Spec.NodeName = ""
+	podList.Items = append(podList.Items, *pending)
+
+	cm := buildClusterMetric(podList, nil, getGroupTestNodeList(), nil, defaultResourceNames)
+
+	grouped := cm.groupBy(QOSGroup)
+	assert.Len(t, grouped.nodeMetrics, 2)
+	assert.Nil(t, cm.nodeMetrics["node-1"].classes)
+
+	// Classes of the cluster exclude unscheduled pods and are a share of
+	// the whole cluster.
+	assert.Len(t, grouped.classes, 2)
+	burstable := grouped.classes["Burstable"]
+	assert.Equal(t, int64(2), burstable.podCount.current)
+	ensureEqualResourceMetric(t, burstable.resources[corev1.ResourceCPU], &ResourceMetric{
+		allocatable: resource.MustParse("4"),
+		request:     resource.MustParse("600m"),
+		limit:       resource.MustParse("1200m"),
+	})
+	assert.Len(t, grouped.classes[NoneGroupName].podMetrics, 1)
+
+	// Classes of a node are a share of the node.
+	node1 := grouped.nodeMetrics["node-1"]
+	assert.Equal(t, cm.nodeMetrics["node-1"].resources, node1.resources)
+	assert.Len(t, node1.classes, 2)
+	ensureEqualResourceMetric(t, node1.classes["Burstable"].resources[corev1.ResourceCPU], &ResourceMetric{
+		allocatable: resource.MustParse("2"),
+		request:     resource.MustParse("200m"),
+		limit:       resource.MustParse("400m"),
+	})
+	assert.Equal(t, "200m (10%%)", node1.classes["Burstable"].resources[corev1.ResourceCPU].requestString(false))
+	assert.Len(t, grouped.unscheduled.classes["Burstable"].podMetrics, 1)
+
+	sorted := getSortedClassMetrics(node1.classes, "cpu.request")
+	assert.Equal(t, "Burstable", sorted[0].name)
+	assert.Equal(t, NoneGroupName, sorted[1].name)
+
+	byPriority := cm.groupBy(PriorityGroup)
+	assert.Len(t, byPriority.classes, 2)
+	assert.Len(t, byPriority.classes["system-cluster-critical"].podMetrics, 1)
+	assert.Len(t, byPriority.classes[NoneGroupName].podMetrics, 2)
+	assert.Equal(t, "NODE", groupHeader(Options{GroupBy: PriorityGroup}))
+}
+
+func TestBuildListClusterMetricsGroupByClass(t *testing.T) {
+	podList := getGroupTestPodList()
+	podList.Items[0].Status.QOSClass = corev1.PodQOSGuaranteed
+	podList.Items[1].Status.QOSClass = corev1.PodQOSBurstable
+	podList.Items[2].Status.QOSClass = corev1.PodQOSBurstable
+
+	cm := buildClusterMetric(podList, nil, getGroupTestNodeList(), nil, defaultResourceNames)
+
+	lp := listPrinter{
+		cm: cm.groupBy(QOSGroup),
+		opts: Options{
+			GroupBy:      QOSGroup,
+			ShowPods:     true,
+			ShowPodCount: true,
+		},
+	}
+
+	lcm := lp.buildListClusterMetrics()
+
+	assert.Len(t, lcm.ClusterTotals.QOSClasses, 2)
+	assert.Nil(t, lcm.ClusterTotals.PriorityClasses)
+	assert.Equal(t, "Burstable", lcm.ClusterTotals.QOSClasses[0].Name)
+	assert.Equal(t, "2/220", lcm.ClusterTotals.QOSClasses[0].PodCount)
+	assert.Nil(t, lcm.ClusterTotals.QOSClasses[0].Pods)
+
+	assert.Len(t, lcm.Nodes, 2)
+	node1 := lcm.Nodes[0]
+	assert.Nil(t, node1.Pods)
+	assert.Len(t, node1.QOSClasses, 2)
+	assert.Equal(t, "Guaranteed", node1.QOSClasses[1].Name)
+	assert.Equal(t, "1/110", node1.QOSClasses[1].PodCount)
+	assert.Equal(t, "web-1", node1.QOSClasses[1].Pods[0].Name)
+	assert.Equal(t, "Guaranteed", node1.QOSClasses[1].Pods[0].QOSClass)
+	assert.Empty(t, node1.QOSClasses[1].Pods[0].PriorityClass)
+}
+
+func getGroupTestNodeList() *corev1.NodeList {
+	allocatable := corev1.ResourceList{
+		"cpu":    resource.MustParse("2"),
+		"memory": resource.MustParse("4Gi"),
+		"pods":   resource.MustParse("110"),
+	}
+
+	return &corev1.NodeList{
+		Items: []corev1.Node{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node-1",
+					Labels: map[string]string{
+						"topology.kubernetes.io/zone": "zone-a",
+					},
+				},
+				Status: corev1.NodeStatus{
+					Allocatable: allocatable,
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node-2",
+					Labels: map[string]string{
+						"topology.kubernetes.io/zone": "zone-b",
+					},
+				},
+				Status: corev1.NodeStatus{
+					Allocatable: allocatable,
+				},
+			},
+		},
+	}
+}
+
+func getGroupTestPodList() *corev1.PodList {
+	groupTestPod := func(node, namespace, name, cpuRequest, cpuLimit, memoryRequest, memoryLimit string) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: corev1.PodSpec{
+				NodeName: node,
+				Containers: []corev1.Container{
+					{
+						Name: "app",
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								"cpu":    resource.MustParse(cpuRequest),
+								"memory": resource.MustParse(memoryRequest),
+							},
+							Limits: corev1.ResourceList{
+								"cpu":    resource.MustParse(cpuLimit),
+								"memory": resource.MustParse(memoryLimit),
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	return &corev1.PodList{
+		Items: []corev1.Pod{
+			groupTestPod("node-1", "default", "web-1", "200m", "400m", "256Mi", "512Mi"),
+			groupTestPod("node-2", "default", "web-2", "400m", "800m", "256Mi", "512Mi"),
+			groupTestPod("node-1", "kube-system", "dns", "100m", "200m", "1Gi", "2Gi"),
+		},
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	v1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+// maxKubeletRequests limits how many nodes the kubelet Summary API is
+// queried on at once.
+const maxKubeletRequests = 20
+
+// kubeletSummary is the part of a response from the kubelet Summary API
+// that utilization is read from.
+type kubeletSummary struct {
+	Node kubeletUsage        `json:"node"`
+	Pods []kubeletPodSummary `json:"pods"`
+}
+
+type kubeletPodSummary struct {
+	PodRef struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"podRef"`
+	Containers []kubeletContainerSummary `json:"containers"`
+}
+
+type kubeletContainerSummary struct {
+	Name string `json:"name"`
+	kubeletUsage
+}
+
+type kubeletUsage struct {
+	CPU *struct {
+		UsageNanoCores *uint64 `json:"usageNanoCores"`
+	} `json:"cpu"`
+	Memory *struct {
+		WorkingSetBytes *uint64 `json:"workingSetBytes"`
+	} `json:"memory"`
+}
+
+// resourceList returns CPU usage and memory working set in the form used by
+// the Metrics API.
+func (u kubeletUsage) resourceList() corev1.ResourceList {
+	usage := corev1.ResourceList{}
+	if u.CPU != nil && u.CPU.UsageNanoCores != nil {
+		usage[corev1.ResourceCPU] = *resource.NewScaledQuantity(int64(*u.CPU.UsageNanoCores), resource.Nano)
+	}
+	if u.Memory != nil && u.Memory.WorkingSetBytes != nil {
+		usage[corev1.ResourceMemory] = *resource.NewQuantity(int64(*u.Memory.WorkingSetBytes), resource.BinarySI)
+	}
+	return usage
+}
+
+// getKubeletMetrics queries the kubelet Summary API of each node through
+// the API server proxy, returning the utilization of each pod and, if
+// requested, of each node as if it had been listed from the Metrics API.
+// Nodes whose Summary can't be retrieved are reported on stderr and left out
+// of the results, so their utilization shows as missing; an error is only
+// returned if no node could be queried.
+func getKubeletMetrics(ctx context.Context, clientset kubernetes.Interface, nodeList *corev1.NodeList, includeNodes bool) (*v1beta1.PodMetricsList, *v1beta1.NodeMetricsList, error) {
+	summaries := make([]*kubeletSummary, len(nodeList.Items))
+	errs := make([]error, len(nodeList.Items))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxKubeletRequests)
+	for i, node := range nodeList.Items {
+		wg.Add(1)
+		go func(i int, nodeName string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			summaries[i], errs[i] = getKubeletSummary(ctx, clientset, nodeName)
+		}(i, node.Name)
+	}
+	wg.Wait()
+
+	// Every request fails once ctx is cancelled, which isn't worth a warning
+	// for each node.
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var firstErr error
+	failed := 0
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		summaryErr := &SummaryError{Node: nodeList.Items[i].Name, Err: err}
+		if firstErr == nil {
+			firstErr = summaryErr
+		}
+		failed++
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: %v, utilization of this node is missing\n", summaryErr)
+	}
+	if failed > 0 && failed == len(nodeList.Items) {
+		return nil, nil, firstErr
+	}
+
+	pmList := &v1beta1.PodMetricsList{}
+	var nmList *v1beta1.NodeMetricsList
+	if includeNodes {
+		nmList = &v1beta1.NodeMetricsList{}
+	}
+	for i, summary := range summaries {
+		if summary == nil {
+			continue
+		}
+		for _, ps := range summary.Pods {
+			pm := v1beta1.PodMetrics{
+				ObjectMeta: metav1.ObjectMeta{Name: ps.PodRef.Name, Namespace: ps.PodRef.Namespace},
+			}
+			for _, cs := range ps.Containers {
+				pm.Containers = append(pm.Containers, v1beta1.ContainerMetrics{Name: cs.Name, Usage: cs.resourceList()})
+			}
+			pmList.Items = append(pmList.Items, pm)
+		}
+		if nmList != nil {
+			nmList.Items = append(nmList.Items, v1beta1.NodeMetrics{
+				ObjectMeta: metav1.ObjectMeta{Name: nodeList.Items[i].Name},
+				Usage:      summary.Node.resourceList(),
+			})
+		}
+	}
+
+	return pmList, nmList, nil
+}
+
+func getKubeletSummary(ctx context.Context, clientset kubernetes.Interface, nodeName string) (*kubeletSummary, error) {
+	raw, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath("/api/v1/nodes", nodeName, "proxy", "stats", "summary").
+		Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &kubeletSummary{}
+	if err := json.Unmarshal(raw, summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
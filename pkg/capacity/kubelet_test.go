@@ -0,0 +1,143 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const kubeletTestSummary = `{
+  "node": {
+    "nodeName": "mynode",
+    "cpu": {"usageNanoCores": 450000000},
+    "memory": {"workingSetBytes": 1073741824}
+  },
+  "pods": [{
+    "podRef": {"name": "mypod", "namespace": "default"},
+    "containers": [{
+      "name": "app",
+      "cpu": {"usageNanoCores": 120000000},
+      "memory": {"workingSetBytes": 268435456}
+    }, {
+      "name": "sidecar",
+      "cpu": {"usageNanoCores": 5000000}
+    }]
+  }]
+}`
+
+func TestGetKubeletMetrics(t *testing.T) {
+	clientset := newKubeletTestClientset(t, nil)
+
+	nodeList := &corev1.NodeList{Items: []corev1.Node{*node("mynode", nil, false)}}
+	pmList, nmList, err := getKubeletMetrics(context.TODO(), clientset, nodeList, true)
+	assert.NoError(t, err)
+
+	assert.Len(t, nmList.Items, 1)
+	assert.Equal(t, "mynode", nmList.Items[0].Name)
+	assert.Equal(t, "450m", quantityString(nmList.Items[0].Usage[corev1.ResourceCPU]))
+	assert.Equal(t, "1Gi", quantityString(nmList.Items[0].Usage[corev1.ResourceMemory]))
+
+	assert.Len(t, pmList.Items, 1)
+	pm := pmList.Items[0]
+	assert.Equal(t, "default", pm.Namespace)
+	assert.Equal(t, "mypod", pm.Name)
+	assert.Len(t, pm.Containers, 2)
+	assert.Equal(t, "120m", quantityString(pm.Containers[0].Usage[corev1.ResourceCPU]))
+	assert.Equal(t, "256Mi", quantityString(pm.Containers[0].Usage[corev1.ResourceMemory]))
+	assert.Equal(t, "5m", quantityString(pm.Containers[1].Usage[corev1.ResourceCPU]))
+	_, found := pm.Containers[1].Usage[corev1.ResourceMemory]
+	assert.False(t, found)
+
+	_, nmList, err = getKubeletMetrics(context.TODO(), clientset, nodeList, false)
+	assert.NoError(t, err)
+	assert.Nil(t, nmList)
+
+	nodeList.Items = append(nodeList.Items, *node("mynode2", nil, false))
+	pmList, nmList, err = getKubeletMetrics(context.TODO(), clientset, nodeList, true)
+	assert.NoError(t, err)
+	assert.Len(t, nmList.Items, 1)
+	assert.Equal(t, "mynode", nmList.Items[0].Name)
+	assert.Len(t, pmList.Items, 1)
+
+	nodeList.Items = []corev1.Node{*node("mynode2", nil, false)}
+	_, _, err = getKubeletMetrics(context.TODO(), clientset, nodeList, true)
+	var summaryErr *SummaryError
+	assert.True(t, errors.As(err, &summaryErr))
+	assert.Equal(t, "mynode2", summaryErr.Node)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	_, _, err = getKubeletMetrics(ctx, clientset, nodeList, true)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCollectKubeletMetrics(t *testing.T) {
+	n := node("mynode", nil, false)
+	n.Status.Allocatable = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("2"),
+		corev1.ResourceMemory: resource.MustParse("4Gi"),
+	}
+	p := pod("mynode", "default", "mypod", nil)
+	p.Spec.Containers = []corev1.Container{{Name: "app"}, {Name: "sidecar"}}
+
+	clientset := newKubeletTestClientset(t, map[string]interface{}{
+		"/api/v1/nodes": &corev1.NodeList{Items: []corev1.Node{*n}},
+		"/api/v1/pods":  &corev1.PodList{Items: []corev1.Pod{*p}},
+	})
+
+	cm, err := Collect(context.TODO(), clientset, nil, Options{ShowUtil: true, MetricsSource: KubeletSource})
+	assert.NoError(t, err)
+	assert.Equal(t, "450m", quantityString(cm.Resource(corev1.ResourceCPU).Utilization()))
+	assert.Equal(t, "1Gi", quantityString(cm.Resource(corev1.ResourceMemory).Utilization()))
+
+	pods := cm.Nodes()[0].Pods()
+	assert.Len(t, pods, 1)
+	assert.Equal(t, "125m", quantityString(pods[0].Resource(corev1.ResourceCPU).Utilization()))
+	assert.Equal(t, "256Mi", quantityString(pods[0].Resource(corev1.ResourceMemory).Utilization()))
+}
+
+// newKubeletTestClientset returns a clientset for an API server that serves
+// the kubelet Summary API of a node named mynode, along with the given
+// objects keyed by path.
+func newKubeletTestClientset(t *testing.T, objects map[string]interface{}) kubernetes.Interface {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/v1/nodes/mynode/proxy/stats/summary" {
+			_, _ = w.Write([]byte(kubeletTestSummary))
+			return
+		}
+		if obj, found := objects[r.URL.Path]; found {
+			_ = json.NewEncoder(w).Encode(obj)
+			return
+		}
+		http.Error(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`, http.StatusNotFound)
+	}))
+	t.Cleanup(server.Close)
+
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	assert.NoError(t, err)
+	return clientset
+}
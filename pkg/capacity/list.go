@@ -18,29 +18,41 @@ import (
 	"encoding/json"
 	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/yaml"
 )
 
 type listNodeMetric struct {
-	Name     string              `json:"name"`
-	CPU      *listResourceOutput `json:"cpu,omitempty"`
-	Memory   *listResourceOutput `json:"memory,omitempty"`
-	Pods     []*listPod          `json:"pods,omitempty"`
-	PodCount string              `json:"podCount,omitempty"`
+	Name      string                         `json:"name"`
+	CPU       *listResourceOutput            `json:"cpu,omitempty"`
+	Memory    *listResourceOutput            `json:"memory,omitempty"`
+	Resources map[string]*listResourceOutput `json:"resources,omitempty"`
+	Pods      []*listPod                     `json:"pods,omitempty"`
+	PodCount  string                         `json:"podCount,omitempty"`
+	Replicas  int64                          `json:"replicas,omitempty"`
+
+	QOSClasses      []*listNodeMetric `json:"qosClasses,omitempty"`
+	PriorityClasses []*listNodeMetric `json:"priorityClasses,omitempty"`
 }
 
 type listPod struct {
-	Name       string              `json:"name"`
-	Namespace  string              `json:"namespace"`
-	CPU        *listResourceOutput `json:"cpu"`
-	Memory     *listResourceOutput `json:"memory"`
-	Containers []listContainer     `json:"containers,omitempty"`
+	Name          string                         `json:"name"`
+	Namespace     string                         `json:"namespace"`
+	PendingReason string                         `json:"pendingReason,omitempty"`
+	QOSClass      string                         `json:"qosClass,omitempty"`
+	PriorityClass string                         `json:"priorityClass,omitempty"`
+	CPU           *listResourceOutput            `json:"cpu,omitempty"`
+	Memory        *listResourceOutput            `json:"memory,omitempty"`
+	Resources     map[string]*listResourceOutput `json:"resources,omitempty"`
+	Containers    []listContainer                `json:"containers,omitempty"`
 }
 
 type listContainer struct {
-	Name   string              `json:"name"`
-	CPU    *listResourceOutput `json:"cpu"`
-	Memory *listResourceOutput `json:"memory"`
+	Name      string                         `json:"name"`
+	Type      string                         `json:"type,omitempty"`
+	CPU       *listResourceOutput            `json:"cpu,omitempty"`
+	Memory    *listResourceOutput            `json:"memory,omitempty"`
+	Resources map[string]*listResourceOutput `json:"resources,omitempty"`
 }
 
 type listResourceOutput struct {
@@ -50,22 +62,50 @@ type listResourceOutput struct {
 	LimitsPct      string `json:"limitsPercent,omitempty"`
 	Utilization    string `json:"utilization,omitempty"`
 	UtilizationPct string `json:"utilizationPercent,omitempty"`
+
+	UtilizationAvg    string `json:"utilizationAvg,omitempty"`
+	UtilizationAvgPct string `json:"utilizationAvgPercent,omitempty"`
+	UtilizationMax    string `json:"utilizationMax,omitempty"`
+	UtilizationMaxPct string `json:"utilizationMaxPercent,omitempty"`
+	UtilizationP95    string `json:"utilizationP95,omitempty"`
+	UtilizationP95Pct string `json:"utilizationP95Percent,omitempty"`
 }
 
 type listClusterMetrics struct {
-	Nodes         []*listNodeMetric  `json:"nodes"`
+	Clusters      []*listCluster     `json:"clusters,omitempty"`
+	Nodes         []*listNodeMetric  `json:"nodes,omitempty"`
+	Namespaces    []*listNodeMetric  `json:"namespaces,omitempty"`
+	NodeGroups    []*listNodeMetric  `json:"nodeGroups,omitempty"`
+	Workloads     []*listNodeMetric  `json:"workloads,omitempty"`
+	Unscheduled   *listNodeMetric    `json:"unscheduled,omitempty"`
 	ClusterTotals *listClusterTotals `json:"clusterTotals"`
 }
 
+// listCluster is a cluster gathered from a kubeconfig context. Clusters
+// that could not be reached only have an error.
+type listCluster struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+	*listClusterMetrics
+}
+
 type listClusterTotals struct {
-	CPU      *listResourceOutput `json:"cpu"`
-	Memory   *listResourceOutput `json:"memory"`
-	PodCount string              `json:"podCount,omitempty"`
+	CPU       *listResourceOutput            `json:"cpu,omitempty"`
+	Memory    *listResourceOutput            `json:"memory,omitempty"`
+	Resources map[string]*listResourceOutput `json:"resources,omitempty"`
+	PodCount  string                         `json:"podCount,omitempty"`
+
+	QOSClasses      []*listNodeMetric `json:"qosClasses,omitempty"`
+	PriorityClasses []*listNodeMetric `json:"priorityClasses,omitempty"`
 }
 
 type listPrinter struct {
-	cm   *clusterMetric
+	cm   *ClusterMetric
 	opts Options
+
+	// clusters holds the clusters to print when gathering data from
+	// several contexts, in which case cm holds their fleet totals.
+	clusters []*contextMetric
 }
 
 func (lp listPrinter) Print(outputType string) {
@@ -97,51 +137,143 @@ func (lp *listPrinter) buildListClusterMetrics() listClusterMetrics {
 	var response listClusterMetrics
 
 	response.ClusterTotals = &listClusterTotals{
-		CPU:    lp.buildListResourceOutput(lp.cm.cpu),
-		Memory: lp.buildListResourceOutput(lp.cm.memory),
+		CPU:       lp.buildListResourceOutput(lp.cm.resources[corev1.ResourceCPU]),
+		Memory:    lp.buildListResourceOutput(lp.cm.resources[corev1.ResourceMemory]),
+		Resources: lp.buildListOtherResources(lp.cm.resources),
 	}
 
 	if lp.opts.ShowPodCount {
 		response.ClusterTotals.PodCount = lp.cm.podCount.podCountString()
 	}
 
-	for _, nodeMetric := range lp.cm.getSortedNodeMetrics(lp.opts.SortBy) {
-		var node listNodeMetric
-		node.Name = nodeMetric.name
-		node.CPU = lp.buildListResourceOutput(nodeMetric.cpu)
-		node.Memory = lp.buildListResourceOutput(nodeMetric.memory)
+	// Pods are listed with their nodes rather than with the classes of the
+	// cluster.
+	totals := &listPrinter{cm: lp.cm, opts: lp.opts}
+	totals.opts.ShowPods, totals.opts.ShowContainers = false, false
+	response.ClusterTotals.QOSClasses, response.ClusterTotals.PriorityClasses = totals.buildListClasses(lp.cm.classes)
 
-		if lp.opts.ShowPodCount {
-			node.PodCount = nodeMetric.podCount.podCountString()
+	for _, c := range lp.clusters {
+		cluster := &listCluster{Name: c.name}
+		if c.err != nil {
+			cluster.Error = c.err.Error()
+		} else {
+			clp := &listPrinter{cm: c.cm, opts: lp.opts}
+			clm := clp.buildListClusterMetrics()
+			cluster.listClusterMetrics = &clm
 		}
+		response.Clusters = append(response.Clusters, cluster)
+	}
 
-		if lp.opts.ShowPods || lp.opts.ShowContainers {
-			for _, podMetric := range nodeMetric.getSortedPodMetrics(lp.opts.SortBy) {
-				var pod listPod
-				pod.Name = podMetric.name
-				pod.Namespace = podMetric.namespace
-				pod.CPU = lp.buildListResourceOutput(podMetric.cpu)
-				pod.Memory = lp.buildListResourceOutput(podMetric.memory)
-
-				if lp.opts.ShowContainers {
-					for _, containerMetric := range podMetric.getSortedContainerMetrics(lp.opts.SortBy) {
-						pod.Containers = append(pod.Containers, listContainer{
-							Name:   containerMetric.name,
-							Memory: lp.buildListResourceOutput(containerMetric.memory),
-							CPU:    lp.buildListResourceOutput(containerMetric.cpu),
-						})
-					}
+	for _, nodeMetric := range lp.cm.getSortedNodeMetrics(lp.opts.SortBy) {
+		switch {
+		case lp.opts.GroupNodesBy != "":
+			response.NodeGroups = append(response.NodeGroups, lp.buildListNodeMetric(nodeMetric))
+		case lp.opts.GroupBy == NamespaceGroup:
+			response.Namespaces = append(response.Namespaces, lp.buildListNodeMetric(nodeMetric))
+		case lp.opts.GroupBy == WorkloadGroup:
+			response.Workloads = append(response.Workloads, lp.buildListNodeMetric(nodeMetric))
+		default:
+			response.Nodes = append(response.Nodes, lp.buildListNodeMetric(nodeMetric))
+		}
+	}
+
+	if lp.cm.unscheduled != nil {
+		response.Unscheduled = lp.buildListNodeMetric(lp.cm.unscheduled)
+	}
+
+	return response
+}
+
+func (lp *listPrinter) buildListNodeMetric(nodeMetric *NodeMetric) *listNodeMetric {
+	var node listNodeMetric
+	node.Name = nodeMetric.name
+	node.CPU = lp.buildListResourceOutput(nodeMetric.resources[corev1.ResourceCPU])
+	node.Memory = lp.buildListResourceOutput(nodeMetric.resources[corev1.ResourceMemory])
+	node.Resources = lp.buildListOtherResources(nodeMetric.resources)
+
+	if lp.opts.ShowPodCount {
+		node.PodCount = nodeMetric.podCount.podCountString()
+	}
+
+	if lp.opts.GroupBy == WorkloadGroup {
+		node.Replicas = nodeMetric.podCount.current
+	}
+
+	// When grouping by class, pods are listed with their class.
+	if nodeMetric.classes != nil {
+		node.QOSClasses, node.PriorityClasses = lp.buildListClasses(nodeMetric.classes)
+		return &node
+	}
+
+	if lp.opts.ShowPods || lp.opts.ShowContainers {
+		for _, podMetric := range nodeMetric.getSortedPodMetrics(lp.opts.SortBy) {
+			var pod listPod
+			pod.Name = podMetric.name
+			pod.Namespace = podMetric.namespace
+			pod.PendingReason = podMetric.pendingReason
+			if lp.opts.showQOSClass() {
+				pod.QOSClass = noneIfEmpty(podMetric.qosClass)
+			}
+			if lp.opts.showPriorityClass() {
+				pod.PriorityClass = noneIfEmpty(podMetric.priorityClass)
+			}
+			pod.CPU = lp.buildListResourceOutput(podMetric.resources[corev1.ResourceCPU])
+			pod.Memory = lp.buildListResourceOutput(podMetric.resources[corev1.ResourceMemory])
+			pod.Resources = lp.buildListOtherResources(podMetric.resources)
+
+			if lp.opts.ShowContainers {
+				for _, containerMetric := range podMetric.getSortedContainerMetrics(lp.opts.SortBy) {
+					pod.Containers = append(pod.Containers, listContainer{
+						Name:      containerMetric.name,
+						Type:      containerMetric.containerType,
+						Memory:    lp.buildListResourceOutput(containerMetric.resources[corev1.ResourceMemory]),
+						CPU:       lp.buildListResourceOutput(containerMetric.resources[corev1.ResourceCPU]),
+						Resources: lp.buildListOtherResources(containerMetric.resources),
+					})
 				}
-				node.Pods = append(node.Pods, &pod)
 			}
+			node.Pods = append(node.Pods, &pod)
 		}
-		response.Nodes = append(response.Nodes, &node)
 	}
 
-	return response
+	return &node
+}
+
+// buildListClasses returns the output for the QoS or priority classes of a
+// node or cluster, as the first or second value depending on which of them
+// output is grouped by.
+func (lp *listPrinter) buildListClasses(classes map[string]*NodeMetric) (qos, priority []*listNodeMetric) {
+	var out []*listNodeMetric
+	for _, class := range getSortedClassMetrics(classes, lp.opts.SortBy) {
+		out = append(out, lp.buildListNodeMetric(class))
+	}
+	if lp.opts.GroupBy == PriorityGroup {
+		return nil, out
+	}
+	return out, nil
+}
+
+// buildListOtherResources returns the output for every resource other than
+// CPU and memory, which have dedicated fields.
+func (lp *listPrinter) buildListOtherResources(resources map[corev1.ResourceName]*ResourceMetric) map[string]*listResourceOutput {
+	var out map[string]*listResourceOutput
+	for name, rm := range resources {
+		if name == corev1.ResourceCPU || name == corev1.ResourceMemory {
+			continue
+		}
+		if out == nil {
+			out = map[string]*listResourceOutput{}
+		}
+		out[string(name)] = lp.buildListResourceOutput(rm)
+	}
+	return out
 }
 
-func (lp *listPrinter) buildListResourceOutput(item *resourceMetric) *listResourceOutput {
+func (lp *listPrinter) buildListResourceOutput(item *ResourceMetric) *listResourceOutput {
+	if item == nil {
+		return nil
+	}
+
 	valueCalculator := item.valueFunction()
 	percentCalculator := item.percentFunction()
 
@@ -157,7 +289,15 @@ func (lp *listPrinter) buildListResourceOutput(item *resourceMetric) *listResour
 		out.LimitsPct = percentCalculator(item.limit)
 	}
 
-	if lp.opts.ShowUtil {
+	if lp.opts.sampled() {
+		avg, maximum, p95 := item.utilizationStat(StatAvg), item.utilizationStat(StatMax), item.utilizationStat(StatP95)
+		out.UtilizationAvg = valueCalculator(avg)
+		out.UtilizationAvgPct = percentCalculator(avg)
+		out.UtilizationMax = valueCalculator(maximum)
+		out.UtilizationMaxPct = percentCalculator(maximum)
+		out.UtilizationP95 = valueCalculator(p95)
+		out.UtilizationP95Pct = percentCalculator(p95)
+	} else if lp.opts.ShowUtil {
 		out.Utilization = valueCalculator(item.utilization)
 		out.UtilizationPct = percentCalculator(item.utilization)
 	}
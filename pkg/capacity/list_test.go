@@ -144,6 +144,7 @@ func TestBuildListClusterMetricsAllOptions(t *testing.T) {
 				Containers: []listContainer{
 					{
 						Name: "example-container-1",
+						Type: AppContainerType,
 						CPU: &listResourceOutput{
 							Requests:       "450m",
 							RequestsPct:    "45%",
@@ -162,6 +163,7 @@ func TestBuildListClusterMetricsAllOptions(t *testing.T) {
 						},
 					}, {
 						Name: "example-container-2",
+						Type: AppContainerType,
 						CPU: &listResourceOutput{
 							Requests:       "200m",
 							RequestsPct:    "20%",
@@ -184,7 +186,7 @@ func TestBuildListClusterMetricsAllOptions(t *testing.T) {
 		}}, lcm.Nodes[0])
 }
 
-func getTestClusterMetric() clusterMetric {
+func getTestClusterMetric() ClusterMetric {
 	return buildClusterMetric(
 		&corev1.PodList{
 			Items: []corev1.Pod{
@@ -278,6 +280,6 @@ func getTestClusterMetric() clusterMetric {
 					},
 				},
 			},
-		},
+		}, defaultResourceNames,
 	)
 }
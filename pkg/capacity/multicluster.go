@@ -0,0 +1,114 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/robscott/kube-capacity/pkg/kube"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// contextMetric is the cluster resource data gathered from a kubeconfig
+// context, or the error that prevented it from being gathered.
+type contextMetric struct {
+	name string
+	cm   *ClusterMetric
+	err  error
+}
+
+// fetchAndPrintContexts gathers cluster resource data from each of the
+// requested contexts concurrently and outputs it along with fleet totals.
+// A ContextError is returned for each cluster that could not be reached,
+// once the others have been printed. If ctx is cancelled, the clusters that
+// were reached before then are printed.
+func fetchAndPrintContexts(ctx context.Context, opts Options) error {
+	names := opts.KubeContexts
+	if opts.AllContexts {
+		var err error
+		names, err = kube.ContextNames(opts.KubeConfig)
+		if err != nil {
+			return fmt.Errorf("reading Kubernetes config: %w", err)
+		}
+	}
+
+	cms := collectContextMetrics(opts, names, func(opts Options) (ClusterMetric, error) {
+		return fetchClusterMetric(ctx, opts, opts.GroupBy == WorkloadGroup)
+	})
+	if err := printContextList(cms, opts); err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, c := range cms {
+		if c.err != nil {
+			errs = append(errs, &ContextError{Context: c.name, Err: c.err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// collectContextMetrics runs fetch for each context concurrently, returning
+// the results in the order the contexts were given.
+func collectContextMetrics(opts Options, names []string, fetch func(opts Options) (ClusterMetric, error)) []*contextMetric {
+	cms := make([]*contextMetric, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+
+			contextOpts := opts
+			contextOpts.KubeContext = name
+			cm, err := fetch(contextOpts)
+			cms[i] = &contextMetric{name: name, err: err}
+			if err == nil {
+				cms[i].cm = &cm
+			}
+		}(i, name)
+	}
+	wg.Wait()
+
+	return cms
+}
+
+// newFleetMetric returns a ClusterMetric without nodes whose resources and
+// pod count are the totals of every cluster that was reached.
+func newFleetMetric(cms []*contextMetric, resourceNames []corev1.ResourceName) *ClusterMetric {
+	fleet := &ClusterMetric{
+		resources:   newResourceMetrics(resourceNames, nil),
+		nodeMetrics: map[string]*NodeMetric{},
+		podCount:    &podCount{},
+	}
+
+	for _, c := range cms {
+		if c.err != nil {
+			continue
+		}
+		for name, rm := range fleet.resources {
+			if crm := c.cm.resources[name]; crm != nil {
+				rm.addMetric(crm)
+			}
+		}
+		fleet.podCount.current += c.cm.podCount.current
+		fleet.podCount.allocatable += c.cm.podCount.allocatable
+	}
+
+	return fleet
+}
@@ -0,0 +1,117 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectContextMetrics(t *testing.T) {
+	opts := Options{KubeContexts: []string{"east", "west", "broken"}}
+	cms := collectContextMetrics(opts, opts.KubeContexts, func(opts Options) (ClusterMetric, error) {
+		if opts.KubeContext == "broken" {
+			return ClusterMetric{}, &ListError{Resource: "Nodes", Err: errors.New("connection refused")}
+		}
+		return getGroupTestClusterMetric(), nil
+	})
+
+	assert.Len(t, cms, 3)
+	assert.Equal(t, "east", cms[0].name)
+	assert.NoError(t, cms[0].err)
+	assert.Equal(t, "west", cms[1].name)
+	assert.NoError(t, cms[1].err)
+	assert.Equal(t, "broken", cms[2].name)
+	assert.EqualError(t, cms[2].err, "listing Nodes: connection refused")
+	assert.Nil(t, cms[2].cm)
+
+	fleet := newFleetMetric(cms, defaultResourceNames)
+	assert.Equal(t, int64(1400), fleet.resources["cpu"].request.MilliValue())
+	assert.Equal(t, int64(8000), fleet.resources["cpu"].allocatable.MilliValue())
+	assert.Equal(t, &podCount{current: 6, allocatable: 440}, fleet.podCount)
+}
+
+func TestPrintContextList(t *testing.T) {
+	cms := getContextTestMetrics()
+	fleet := newFleetMetric(cms, defaultResourceNames)
+
+	var buf bytes.Buffer
+	tp := &tablePrinter{
+		cm:       fleet,
+		clusters: cms,
+		w:        new(tabwriter.Writer),
+		opts:     Options{},
+		out:      &buf,
+	}
+	tp.Print()
+	assert.Equal(t, []string{
+		"CLUSTER   NODE     CPU REQUESTS   CPU LIMITS     MEMORY REQUESTS   MEMORY LIMITS",
+		"*         *        1400m (17%%)   2800m (35%%)   3072Mi (18%%)     6144Mi (37%%)",
+		"east      *        700m (17%%)    1400m (35%%)   1536Mi (18%%)     3072Mi (37%%)",
+		"east      node-1   300m (15%%)    600m (30%%)    1280Mi (31%%)     2560Mi (62%%)",
+		"east      node-2   400m (20%%)    800m (40%%)    256Mi (6%%)       512Mi (12%%)",
+		"west      *        700m (17%%)    1400m (35%%)   1536Mi (18%%)     3072Mi (37%%)",
+		"west      node-1   300m (15%%)    600m (30%%)    1280Mi (31%%)     2560Mi (62%%)",
+		"west      node-2   400m (20%%)    800m (40%%)    256Mi (6%%)       512Mi (12%%)",
+	}, strings.Split(strings.TrimRight(buf.String(), "\n"), "\n"))
+
+	buf.Reset()
+	cp := &csvPrinter{
+		cm:       fleet,
+		clusters: cms,
+		file:     &buf,
+		opts:     Options{OutputFormat: CSVOutput, HideLimits: true, Resources: []string{"cpu"}},
+	}
+	cp.Print(CSVOutput)
+	assert.Equal(t, `"CLUSTER","NODE",CPU CAPACITY (milli),CPU REQUESTS,CPU REQUESTS %%
+"*","*",8000,1400,17
+"east","*",4000,700,17
+"east","node-1",2000,300,15
+"east","node-2",2000,400,20
+"west","*",4000,700,17
+"west","node-1",2000,300,15
+"west","node-2",2000,400,20
+`, buf.String())
+
+	lp := &listPrinter{cm: fleet, clusters: cms}
+	lcm := lp.buildListClusterMetrics()
+	assert.Equal(t, "1400m", lcm.ClusterTotals.CPU.Requests)
+	assert.Len(t, lcm.Clusters, 3)
+	assert.Equal(t, "east", lcm.Clusters[0].Name)
+	assert.Equal(t, "700m", lcm.Clusters[0].ClusterTotals.CPU.Requests)
+	assert.Len(t, lcm.Clusters[0].Nodes, 2)
+	assert.Equal(t, "broken", lcm.Clusters[2].Name)
+	assert.Equal(t, "listing Nodes: connection refused", lcm.Clusters[2].Error)
+	assert.Nil(t, lcm.Clusters[2].listClusterMetrics)
+}
+
+func getGroupTestClusterMetric() ClusterMetric {
+	return buildClusterMetric(getGroupTestPodList(), nil, getGroupTestNodeList(), nil, defaultResourceNames)
+}
+
+func getContextTestMetrics() []*contextMetric {
+	east := getGroupTestClusterMetric()
+	west := getGroupTestClusterMetric()
+	return []*contextMetric{
+		{name: "east", cm: &east},
+		{name: "west", cm: &west},
+		{name: "broken", err: &ListError{Resource: "Nodes", Err: errors.New("connection refused")}},
+	}
+}
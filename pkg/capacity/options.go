@@ -1,26 +1,103 @@
 package capacity
 
+import (
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
 // Options is a struct containing the command line options
 // FetchAndPrint depends on
 type Options struct {
-	ShowContainers        bool
-	ShowPods              bool
-	ShowUtil              bool
-	ShowPodCount          bool
-	HideRequests          bool
-	HideLimits            bool
-	PodLabels             string
-	NodeLabels            string
-	NodeTaints            string
-	ExcludeTainted        bool
-	NamespaceLabels       string
-	Namespace             string
-	KubeContext           string
-	KubeConfig            string
-	InsecureSkipTLSVerify bool
-	OutputFormat          string
-	SortBy                string
-	AvailableFormat       bool
-	ImpersonateUser       string
-	ImpersonateGroup      string
+	ShowContainers         bool
+	ShowPods               bool
+	ShowUtil               bool
+	MetricsSource          string
+	PrometheusURL          string
+	Window                 string
+	Stat                   string
+	Samples                int
+	SampleInterval         time.Duration
+	ShowPodCount           bool
+	ShowPending            bool
+	ShowClasses            bool
+	HideRequests           bool
+	HideLimits             bool
+	PodLabels              string
+	FieldSelector          string
+	NodeLabels             string
+	NodeTaints             string
+	ExcludeTainted         bool
+	NamespaceLabels        string
+	Namespace              string
+	KubeContext            string
+	KubeConfig             string
+	InsecureSkipTLSVerify  bool
+	RequestTimeout         string
+	ChunkSize              int64
+	Verbose                bool
+	OutputFormat           string
+	SortBy                 string
+	GroupBy                string
+	GroupNodesBy           string
+	AvailableFormat        bool
+	ImpersonateUser        string
+	ImpersonateGroup       string
+	Resources              []string
+	RequestHeadroom        float64
+	LimitHeadroom          float64
+	Tolerance              float64
+	ShowAllRecommendations bool
+	FailIf                 []string
+	Policy                 string
+	Watch                  bool
+	WatchInterval          time.Duration
+	ListenAddress          string
+	RefreshInterval        time.Duration
+	FromFiles              []string
+	KubeContexts           []string
+	AllContexts            bool
+}
+
+// resourceNames returns the resources that should be included in output,
+// defaulting to CPU and memory when none have been specified.
+func (opts Options) resourceNames() []corev1.ResourceName {
+	resourceNames := []corev1.ResourceName{}
+	seen := map[corev1.ResourceName]bool{}
+	for _, r := range opts.Resources {
+		name := corev1.ResourceName(strings.TrimSpace(r))
+		if alias, found := resourceAliases[string(name)]; found {
+			name = alias
+		}
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		resourceNames = append(resourceNames, name)
+	}
+
+	if len(resourceNames) == 0 {
+		return defaultResourceNames
+	}
+	return resourceNames
+}
+
+// multiCluster returns true if resource data should be gathered from more
+// than one kubeconfig context.
+func (opts Options) multiCluster() bool {
+	return len(opts.KubeContexts) > 0 || opts.AllContexts
+}
+
+// showQOSClass returns true if output should include a column for the QoS
+// class of each pod, or of each group of pods when grouping by QoS class.
+func (opts Options) showQOSClass() bool {
+	return opts.GroupBy == QOSGroup || (opts.ShowClasses && (opts.ShowPods || opts.ShowContainers))
+}
+
+// showPriorityClass returns true if output should include a column for the
+// priority class of each pod, or of each group of pods when grouping by
+// priority class.
+func (opts Options) showPriorityClass() bool {
+	return opts.GroupBy == PriorityGroup || (opts.ShowClasses && (opts.ShowPods || opts.ShowContainers))
 }
@@ -0,0 +1,104 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// policy is a set of rules read from a policy file, such as:
+//
+//	rules:
+//	- name: memory-limits
+//	  description: Every container in team-a must have a memory limit
+//	  scope: container
+//	  namespaces: [team-a]
+//	  failIf: mem.limit<=0
+type policy struct {
+	Rules []policyRule `json:"rules"`
+}
+
+// policyRule fails when any row in its scope, optionally limited to some
+// namespaces, meets its failIf condition.
+type policyRule struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Scope       string   `json:"scope"`
+	Namespaces  []string `json:"namespaces,omitempty"`
+	FailIf      string   `json:"failIf"`
+}
+
+// loadPolicy returns a threshold for each rule of a policy file. Unknown
+// fields are rejected so that misspelled rules are not silently ignored.
+func loadPolicy(file string) ([]*threshold, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, &OptionError{Option: "policy", Err: err}
+	}
+
+	p := &policy{}
+	if err := yaml.UnmarshalStrict(raw, p); err != nil {
+		return nil, &OptionError{Option: "policy", Err: fmt.Errorf("%s: %w", file, err)}
+	}
+
+	thresholds := make([]*threshold, 0, len(p.Rules))
+	names := map[string]bool{}
+	for i, rule := range p.Rules {
+		t, err := rule.threshold()
+		if err == nil && names[rule.Name] {
+			err = fmt.Errorf("rule %q is defined more than once", rule.Name)
+		}
+		if err != nil {
+			return nil, &OptionError{Option: "policy", Err: fmt.Errorf("%s: rule %d: %w", file, i+1, err)}
+		}
+		names[rule.Name] = true
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, nil
+}
+
+// threshold returns the threshold a rule is checked with.
+func (r policyRule) threshold() (*threshold, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if r.FailIf == "" {
+		return nil, fmt.Errorf("failIf is required")
+	}
+
+	t, err := parseThreshold(r.Scope + ":" + r.FailIf)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Namespaces) > 0 {
+		switch r.Scope {
+		case NamespaceScope, PodScope, ContainerScope:
+		default:
+			return nil, fmt.Errorf("namespaces can only be used with %s, %s or %s rules", NamespaceScope, PodScope, ContainerScope)
+		}
+		t.namespaces = map[string]bool{}
+		for _, namespace := range r.Namespaces {
+			t.namespaces[namespace] = true
+		}
+	}
+
+	t.name = r.Name
+	t.description = r.Description
+	return t, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPolicy = `rules:
+- name: memory-limits
+  description: Every container in default must have a memory limit
+  scope: container
+  namespaces: [default]
+  failIf: mem.limit<=0
+- name: cpu-limit-overcommit
+  scope: node
+  failIf: cpu.limit.percentage>150
+`
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "policy.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(testPolicy), 0o600))
+
+	thresholds, err := loadPolicy(file)
+	assert.NoError(t, err)
+	assert.Len(t, thresholds, 2)
+	assert.Equal(t, "memory-limits", thresholds[0].name)
+	assert.Equal(t, "container:mem.limit<=0", thresholds[0].expr)
+	assert.Equal(t, map[string]bool{"default": true}, thresholds[0].namespaces)
+	assert.Equal(t, "cpu-limit-overcommit", thresholds[1].name)
+	assert.Equal(t, NodeScope, thresholds[1].scope)
+	assert.True(t, thresholds[1].percentage)
+
+	var testCases = []struct {
+		name   string
+		policy string
+		err    string
+	}{
+		{"unknown field", "rules:\n- name: a\n  scope: node\n  condition: cpu.request>1\n", `unknown field "condition"`},
+		{"missing name", "rules:\n- scope: node\n  failIf: cpu.request>1\n", "rule 1: name is required"},
+		{"missing condition", "rules:\n- name: a\n  scope: node\n", "rule 1: failIf is required"},
+		{"duplicate name", "rules:\n- name: a\n  scope: node\n  failIf: cpu.request>1\n- name: a\n  scope: pod\n  failIf: cpu.request>1\n", `rule 2: rule "a" is defined more than once`},
+		{"namespaces on nodes", "rules:\n- name: a\n  scope: node\n  namespaces: [default]\n  failIf: cpu.request>1\n", "rule 1: namespaces can only be used"},
+		{"invalid condition", "rules:\n- name: a\n  scope: node\n  failIf: cpu.request=1\n", "rule 1: \"node:cpu.request=1\" must compare"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := filepath.Join(dir, "invalid.yaml")
+			assert.NoError(t, os.WriteFile(file, []byte(tc.policy), 0o600))
+
+			_, err := loadPolicy(file)
+			var optErr *OptionError
+			assert.True(t, errors.As(err, &optErr))
+			assert.Equal(t, "policy", optErr.Option)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+
+	_, err = loadPolicy(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
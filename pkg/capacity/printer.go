@@ -16,7 +16,6 @@ package capacity
 
 import (
 	"fmt"
-	"os"
 	"text/tabwriter"
 )
 
@@ -44,37 +43,56 @@ func SupportedOutputs() []string {
 	}
 }
 
-func printList(cm *clusterMetric, opts Options) {
+func printList(cm *ClusterMetric, opts Options) error {
+	return printClusterMetrics(cm.group(opts), nil, opts)
+}
+
+// printContextList outputs the fleet totals of several clusters followed by
+// each of the clusters that were reached.
+func printContextList(cms []*contextMetric, opts Options) error {
+	grouped := make([]*contextMetric, 0, len(cms))
+	for _, c := range cms {
+		if c.err != nil {
+			grouped = append(grouped, c)
+			continue
+		}
+		grouped = append(grouped, &contextMetric{name: c.name, cm: c.cm.group(opts)})
+	}
+
+	return printClusterMetrics(newFleetMetric(cms, opts.resourceNames()), grouped, opts)
+}
+
+// printClusterMetrics outputs a ClusterMetric. When clusters are given, the
+// ClusterMetric holds their fleet totals.
+func printClusterMetrics(cm *ClusterMetric, clusters []*contextMetric, opts Options) error {
 	output := opts.OutputFormat
 	if output == JSONOutput || output == YAMLOutput {
 		lp := &listPrinter{
-			cm:   cm,
-			opts: opts,
+			cm:       cm,
+			clusters: clusters,
+			opts:     opts,
 		}
 		lp.Print(output)
 	} else if output == TableOutput {
 		tp := &tablePrinter{
-			cm:   cm,
-			w:    new(tabwriter.Writer),
-			opts: opts,
+			cm:       cm,
+			clusters: clusters,
+			w:        new(tabwriter.Writer),
+			opts:     opts,
 		}
 		if !tp.hasVisibleColumns() {
-			fmt.Fprintln(os.Stderr, "Error: No data columns selected for display. At least one of the following must be enabled:")
-			fmt.Fprintln(os.Stderr, "- Resource requests (enabled by default, disabled with --hide-requests)")
-			fmt.Fprintln(os.Stderr, "- Resource limits (enabled by default, disabled with --hide-limits)")
-			fmt.Fprintln(os.Stderr, "- Resource utilization (enabled with --util)")
-			fmt.Fprintln(os.Stderr, "- Pod count (enabled with --pod-count)")
-			os.Exit(1)
+			return ErrNoVisibleColumns
 		}
 		tp.Print()
 	} else if output == CSVOutput || output == TSVOutput {
 		cp := &csvPrinter{
-			cm:   cm,
-			opts: opts,
+			cm:       cm,
+			clusters: clusters,
+			opts:     opts,
 		}
 		cp.Print(output)
 	} else {
-		fmt.Fprintf(os.Stderr, "Called with an unsupported output type: %s\n", output)
-		os.Exit(1)
+		return fmt.Errorf("unsupported output type: %s", output)
 	}
+	return nil
 }
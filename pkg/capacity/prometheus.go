@@ -0,0 +1,307 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/robscott/kube-capacity/pkg/kube"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+const (
+	//StatP95 is the constant value for the 95th percentile of utilization over a window
+	StatP95 string = "p95"
+	//StatMax is the constant value for the maximum utilization over a window
+	StatMax string = "max"
+	//StatAvg is the constant value for the average utilization over a window
+	StatAvg string = "avg"
+)
+
+// SupportedStats returns a string list of statistics that utilization over
+// a window can be summarized with
+func SupportedStats() []string {
+	return []string{
+		StatP95,
+		StatMax,
+		StatAvg,
+	}
+}
+
+// prometheusResolution is the interval at which utilization is sampled over
+// a window, and over which CPU usage rates are calculated.
+const prometheusResolution = "5m"
+
+// prometheusContainerSelector selects the series of containers, excluding
+// those of pod sandboxes and the cgroups of whole pods.
+const prometheusContainerSelector = `container!="",container!="POD"`
+
+// prometheusWindowRegexp matches durations in the format used by
+// Prometheus, e.g. "7d" or "1h30m".
+var prometheusWindowRegexp = regexp.MustCompile(`^([0-9]+(ms|s|m|h|d|w|y))+$`)
+
+// ValidPrometheusWindow returns true if a window is a duration in the format
+// used by Prometheus
+func ValidPrometheusWindow(window string) bool {
+	return prometheusWindowRegexp.MatchString(window)
+}
+
+// prometheusResponse is the part of a response from the Prometheus HTTP API
+// to an instant query that returned a vector.
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string             `json:"resultType"`
+		Result     []prometheusSample `json:"result"`
+	} `json:"data"`
+}
+
+type prometheusSample struct {
+	Metric map[string]string `json:"metric"`
+	// Value holds the timestamp of the sample and its value as a string.
+	Value [2]interface{} `json:"value"`
+}
+
+// value returns the value of a sample, which is 0 if it can't be parsed or
+// is not a number.
+func (s prometheusSample) value() float64 {
+	str, ok := s.Value[1].(string)
+	if !ok {
+		return 0
+	}
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return v
+}
+
+// prometheusQuery returns a query for a statistic of an expression over a
+// window.
+func prometheusQuery(stat, window, expr string) string {
+	windowed := fmt.Sprintf("(%s)[%s:%s]", expr, window, prometheusResolution)
+	switch stat {
+	case StatMax:
+		return fmt.Sprintf("max_over_time(%s)", windowed)
+	case StatAvg:
+		return fmt.Sprintf("avg_over_time(%s)", windowed)
+	default:
+		return fmt.Sprintf("quantile_over_time(0.95, %s)", windowed)
+	}
+}
+
+// prometheusQueries returns the queries for CPU and memory utilization of
+// each container, or of each node when byNode is set.
+func prometheusQueries(opts Options, byNode bool) map[corev1.ResourceName]string {
+	selector := prometheusContainerSelector
+	if opts.Namespace != "" {
+		selector += fmt.Sprintf(",namespace=%q", opts.Namespace)
+	}
+
+	// Series for the same container can be duplicated, e.g. while an image
+	// is being replaced, so only the largest is counted.
+	aggregate := func(expr string) string {
+		return fmt.Sprintf("max by (namespace, pod, container) (%s)", expr)
+	}
+	if byNode {
+		aggregate = func(expr string) string {
+			return fmt.Sprintf("sum by (node) (max by (node, namespace, pod, container) (%s))", expr)
+		}
+	}
+
+	cpu := fmt.Sprintf("rate(container_cpu_usage_seconds_total{%s}[%s])", selector, prometheusResolution)
+	memory := fmt.Sprintf("container_memory_working_set_bytes{%s}", selector)
+	return map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    prometheusQuery(opts.Stat, opts.Window, aggregate(cpu)),
+		corev1.ResourceMemory: prometheusQuery(opts.Stat, opts.Window, aggregate(memory)),
+	}
+}
+
+// getPrometheusMetrics queries Prometheus for the utilization of each
+// container and, if requested, of each node over a window, returning it as
+// if it had been listed from the Metrics API. Nodes are identified by the
+// node label that is added to cAdvisor metrics when they are scraped from
+// the kubelet.
+func getPrometheusMetrics(ctx context.Context, opts Options, includeNodes bool) (*v1beta1.PodMetricsList, *v1beta1.NodeMetricsList, error) {
+	client, err := kube.NewHTTPClient(opts.RequestTimeout)
+	if err != nil {
+		return nil, nil, &ConnectionError{API: PrometheusAPI, Err: err}
+	}
+
+	g := newFetchGroup(ctx)
+	defer g.cancel()
+
+	var mu sync.Mutex
+	containerUsage := map[corev1.ResourceName][]prometheusSample{}
+	nodeUsage := map[corev1.ResourceName][]prometheusSample{}
+	run := func(usage map[corev1.ResourceName][]prometheusSample, queries map[corev1.ResourceName]string) {
+		for name, query := range queries {
+			g.Go(string(name), func(ctx context.Context) error {
+				samples, err := queryPrometheus(ctx, client, opts.PrometheusURL, query)
+				mu.Lock()
+				defer mu.Unlock()
+				usage[name] = samples
+				return err
+			})
+		}
+	}
+	run(containerUsage, prometheusQueries(opts, false))
+	if includeNodes {
+		run(nodeUsage, prometheusQueries(opts, true))
+	}
+	if err := g.Wait(); err != nil {
+		return nil, nil, err
+	}
+
+	return prometheusPodMetrics(containerUsage), prometheusNodeMetrics(nodeUsage, includeNodes), nil
+}
+
+// prometheusPodMetrics returns the utilization of each container, grouped
+// by pod, in the order of their namespaces and names.
+func prometheusPodMetrics(usage map[corev1.ResourceName][]prometheusSample) *v1beta1.PodMetricsList {
+	pods := map[[2]string]map[string]corev1.ResourceList{}
+	for name, samples := range usage {
+		for _, sample := range samples {
+			key := [2]string{sample.Metric["namespace"], sample.Metric["pod"]}
+			if pods[key] == nil {
+				pods[key] = map[string]corev1.ResourceList{}
+			}
+			container := sample.Metric["container"]
+			if pods[key][container] == nil {
+				pods[key][container] = corev1.ResourceList{}
+			}
+			pods[key][container][name] = prometheusQuantity(name, sample.value())
+		}
+	}
+
+	keys := make([][2]string, 0, len(pods))
+	for key := range pods {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+
+	pmList := &v1beta1.PodMetricsList{}
+	for _, key := range keys {
+		pm := v1beta1.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key[0], Name: key[1]},
+		}
+		containers := make([]string, 0, len(pods[key]))
+		for container := range pods[key] {
+			containers = append(containers, container)
+		}
+		sort.Strings(containers)
+		for _, container := range containers {
+			pm.Containers = append(pm.Containers, v1beta1.ContainerMetrics{Name: container, Usage: pods[key][container]})
+		}
+		pmList.Items = append(pmList.Items, pm)
+	}
+	return pmList
+}
+
+// prometheusNodeMetrics returns the utilization of each node in the order
+// of their names, or nil if node utilization was not requested.
+func prometheusNodeMetrics(usage map[corev1.ResourceName][]prometheusSample, includeNodes bool) *v1beta1.NodeMetricsList {
+	if !includeNodes {
+		return nil
+	}
+
+	nodes := map[string]corev1.ResourceList{}
+	for name, samples := range usage {
+		for _, sample := range samples {
+			node := sample.Metric["node"]
+			if nodes[node] == nil {
+				nodes[node] = corev1.ResourceList{}
+			}
+			nodes[node][name] = prometheusQuantity(name, sample.value())
+		}
+	}
+
+	names := make([]string, 0, len(nodes))
+	for node := range nodes {
+		names = append(names, node)
+	}
+	sort.Strings(names)
+
+	nmList := &v1beta1.NodeMetricsList{}
+	for _, node := range names {
+		nmList.Items = append(nmList.Items, v1beta1.NodeMetrics{
+			ObjectMeta: metav1.ObjectMeta{Name: node},
+			Usage:      nodes[node],
+		})
+	}
+	return nmList
+}
+
+// prometheusQuantity converts a value from Prometheus, which is in cores for
+// CPU and bytes for memory, to a quantity.
+func prometheusQuantity(name corev1.ResourceName, value float64) resource.Quantity {
+	if name == corev1.ResourceCPU {
+		return *resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI)
+	}
+	return *resource.NewQuantity(int64(math.Round(value)), resource.BinarySI)
+}
+
+// queryPrometheus runs an instant query with the Prometheus HTTP API,
+// returning the samples of the resulting vector.
+func queryPrometheus(ctx context.Context, client *http.Client, baseURL, query string) ([]prometheusSample, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, &ConnectionError{API: PrometheusAPI, Err: err}
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v1/query"
+	u.RawQuery = url.Values{"query": []string{query}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, &PrometheusError{Query: query, Err: err}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &PrometheusError{Query: query, Err: err}
+	}
+	defer resp.Body.Close()
+
+	pr := &prometheusResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(pr); err != nil {
+		return nil, &PrometheusError{Query: query, Err: fmt.Errorf("unexpected response with status %s", resp.Status)}
+	}
+	if pr.Status != "success" {
+		return nil, &PrometheusError{Query: query, Err: errors.New(pr.Error)}
+	}
+	if pr.Data.ResultType != "vector" {
+		return nil, &PrometheusError{Query: query, Err: fmt.Errorf("unexpected result type %s", pr.Data.ResultType)}
+	}
+	return pr.Data.Result, nil
+}
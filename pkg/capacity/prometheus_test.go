@@ -0,0 +1,146 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPrometheusQueries(t *testing.T) {
+	queries := prometheusQueries(Options{Stat: StatP95, Window: "7d", Namespace: "default"}, false)
+	assert.Equal(t, `quantile_over_time(0.95, (max by (namespace, pod, container) (rate(container_cpu_usage_seconds_total{container!="",container!="POD",namespace="default"}[5m])))[7d:5m])`, queries[corev1.ResourceCPU])
+	assert.Equal(t, `quantile_over_time(0.95, (max by (namespace, pod, container) (container_memory_working_set_bytes{container!="",container!="POD",namespace="default"}))[7d:5m])`, queries[corev1.ResourceMemory])
+
+	queries = prometheusQueries(Options{Stat: StatMax, Window: "1h"}, true)
+	assert.Equal(t, `max_over_time((sum by (node) (max by (node, namespace, pod, container) (container_memory_working_set_bytes{container!="",container!="POD"})))[1h:5m])`, queries[corev1.ResourceMemory])
+
+	queries = prometheusQueries(Options{Stat: StatAvg, Window: "1d"}, false)
+	assert.True(t, strings.HasPrefix(queries[corev1.ResourceCPU], "avg_over_time("))
+}
+
+func TestValidPrometheusWindow(t *testing.T) {
+	for _, window := range []string{"7d", "1h30m", "90s", "2w"} {
+		assert.True(t, ValidPrometheusWindow(window), window)
+	}
+	for _, window := range []string{"", "7", "1.5h", "d", "7 d"} {
+		assert.False(t, ValidPrometheusWindow(window), window)
+	}
+}
+
+func TestGetPrometheusMetrics(t *testing.T) {
+	server := newPrometheusTestServer(t)
+
+	opts := Options{PrometheusURL: server.URL + "/", Stat: StatP95, Window: "7d"}
+	pmList, nmList, err := getPrometheusMetrics(context.TODO(), opts, true)
+	assert.NoError(t, err)
+
+	assert.Len(t, pmList.Items, 1)
+	pm := pmList.Items[0]
+	assert.Equal(t, "default", pm.Namespace)
+	assert.Equal(t, "mypod", pm.Name)
+	assert.Len(t, pm.Containers, 2)
+	assert.Equal(t, "app", pm.Containers[0].Name)
+	assert.Equal(t, "250m", quantityString(pm.Containers[0].Usage[corev1.ResourceCPU]))
+	assert.Equal(t, "512Mi", quantityString(pm.Containers[0].Usage[corev1.ResourceMemory]))
+	assert.Equal(t, "sidecar", pm.Containers[1].Name)
+	assert.Equal(t, "13m", quantityString(pm.Containers[1].Usage[corev1.ResourceCPU]))
+
+	assert.Len(t, nmList.Items, 1)
+	assert.Equal(t, "mynode", nmList.Items[0].Name)
+	assert.Equal(t, "1500m", quantityString(nmList.Items[0].Usage[corev1.ResourceCPU]))
+	assert.Equal(t, "2Gi", quantityString(nmList.Items[0].Usage[corev1.ResourceMemory]))
+
+	_, nmList, err = getPrometheusMetrics(context.TODO(), opts, false)
+	assert.NoError(t, err)
+	assert.Nil(t, nmList)
+
+	opts.Window = "bad"
+	_, _, err = getPrometheusMetrics(context.TODO(), opts, false)
+	var promErr *PrometheusError
+	assert.True(t, errors.As(err, &promErr))
+	assert.EqualError(t, err, "querying Prometheus: invalid parameter \"query\": bad duration")
+}
+
+func TestCollectPrometheusMetrics(t *testing.T) {
+	server := newPrometheusTestServer(t)
+
+	n := node("mynode", nil, false)
+	n.Status.Allocatable = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("4"),
+		corev1.ResourceMemory: resource.MustParse("8Gi"),
+	}
+	p := pod("mynode", "default", "mypod", nil)
+	p.Spec.Containers = []corev1.Container{{Name: "app"}, {Name: "sidecar"}}
+	clientset := fake.NewSimpleClientset(n, p)
+
+	cm, err := Collect(context.TODO(), clientset, nil, Options{
+		ShowUtil:      true,
+		MetricsSource: PrometheusSource,
+		PrometheusURL: server.URL,
+		Window:        "7d",
+		Stat:          StatMax,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "1500m", quantityString(cm.Resource(corev1.ResourceCPU).Utilization()))
+
+	pods := cm.Nodes()[0].Pods()
+	assert.Equal(t, "263m", quantityString(pods[0].Resource(corev1.ResourceCPU).Utilization()))
+	assert.Equal(t, "512Mi", quantityString(pods[0].Resource(corev1.ResourceMemory).Utilization()))
+}
+
+// newPrometheusTestServer returns a stand-in for the Prometheus HTTP API that
+// answers utilization queries for a pod named mypod on a node named mynode.
+func newPrometheusTestServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		query := r.URL.Query().Get("query")
+		if r.URL.Path != "/api/v1/query" || query == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.Contains(query, "[bad:") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"invalid parameter \"query\": bad duration"}`)
+			return
+		}
+
+		result := ""
+		switch {
+		case strings.Contains(query, "sum by (node)") && strings.Contains(query, "cpu"):
+			result = `{"metric":{"node":"mynode"},"value":[1700000000,"1.5"]}`
+		case strings.Contains(query, "sum by (node)"):
+			result = `{"metric":{"node":"mynode"},"value":[1700000000,"2147483648"]}`
+		case strings.Contains(query, "cpu"):
+			result = `{"metric":{"namespace":"default","pod":"mypod","container":"app"},"value":[1700000000,"0.25"]},` +
+				`{"metric":{"namespace":"default","pod":"mypod","container":"sidecar"},"value":[1700000000,"0.0125"]}`
+		default:
+			result = `{"metric":{"namespace":"default","pod":"mypod","container":"app"},"value":[1700000000,"536870912"]}`
+		}
+		_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[%s]}}`, result)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
@@ -0,0 +1,507 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	//PatchOutput is the constant value for output type kubectl patch YAML
+	PatchOutput string = "patch"
+
+	//OverProvisioned is the status of a container requesting far more than it uses
+	OverProvisioned string = "over-provisioned"
+	//UnderProvisioned is the status of a container using more than it requests
+	UnderProvisioned string = "under-provisioned"
+	//Provisioned is the status of a container with requests close to its usage
+	Provisioned string = "ok"
+)
+
+// minRecommendedRequests are the smallest requests that will be recommended,
+// so idle containers are not left with requests of zero.
+var minRecommendedRequests = map[corev1.ResourceName]resource.Quantity{
+	corev1.ResourceCPU:    resource.MustParse("10m"),
+	corev1.ResourceMemory: resource.MustParse("16Mi"),
+}
+
+// SupportedRecommendOutputs returns a string list of output formats
+// supported by the recommend command
+func SupportedRecommendOutputs() []string {
+	return []string{
+		TableOutput,
+		JSONOutput,
+		YAMLOutput,
+		PatchOutput,
+	}
+}
+
+type recommendation struct {
+	namespace          string
+	pod                string
+	container          string
+	containerType      string
+	workload           workloadRef
+	resourceName       corev1.ResourceName
+	utilization        resource.Quantity
+	request            resource.Quantity
+	limit              resource.Quantity
+	recommendedRequest resource.Quantity
+	recommendedLimit   resource.Quantity
+	status             string
+}
+
+type listRecommendations struct {
+	Recommendations []*listRecommendation `json:"recommendations"`
+}
+
+type listRecommendation struct {
+	Namespace          string `json:"namespace"`
+	Pod                string `json:"pod"`
+	Container          string `json:"container"`
+	Workload           string `json:"workload"`
+	Resource           string `json:"resource"`
+	Status             string `json:"status"`
+	Utilization        string `json:"utilization"`
+	Request            string `json:"request"`
+	RecommendedRequest string `json:"recommendedRequest"`
+	Limit              string `json:"limit,omitempty"`
+	RecommendedLimit   string `json:"recommendedLimit,omitempty"`
+}
+
+// FetchAndRecommend gathers cluster resource data and outputs rightsizing
+// recommendations for each container
+func FetchAndRecommend(ctx context.Context, opts Options) error {
+	opts.ShowUtil = true
+	opts.ShowPending = false
+
+	cm, err := fetchClusterMetric(ctx, opts, true)
+	if err != nil {
+		return err
+	}
+	return printRecommendations(buildRecommendations(&cm, opts), opts)
+}
+
+// buildRecommendations compares the utilization of each scheduled container
+// to its requests and limits.
+func buildRecommendations(cm *ClusterMetric, opts Options) []*recommendation {
+	recommendations := []*recommendation{}
+
+	for _, nm := range cm.nodeMetrics {
+		for _, pm := range nm.podMetrics {
+			for _, ctm := range pm.containerMetrics {
+				// Init and ephemeral containers have no utilization once
+				// they have exited, and pod overhead is not configurable.
+				switch ctm.containerType {
+				case InitContainerType, EphemeralContainerType, OverheadContainerType:
+					continue
+				}
+				// Containers without metrics would otherwise appear idle.
+				if !ctm.measured {
+					continue
+				}
+				for _, name := range recommendResourceNames(opts) {
+					rm := ctm.resources[name]
+					if rm == nil {
+						continue
+					}
+
+					r := newRecommendation(name, rm, opts)
+					r.namespace = pm.namespace
+					r.pod = pm.name
+					r.container = ctm.name
+					r.containerType = ctm.containerType
+					r.workload = pm.workload
+					recommendations = append(recommendations, r)
+				}
+			}
+		}
+	}
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		r1, r2 := recommendations[i], recommendations[j]
+		if r1.namespace != r2.namespace {
+			return r1.namespace < r2.namespace
+		}
+		if r1.pod != r2.pod {
+			return r1.pod < r2.pod
+		}
+		if r1.container != r2.container {
+			return r1.container < r2.container
+		}
+		return r1.resourceName < r2.resourceName
+	})
+
+	return recommendations
+}
+
+// flaggedRecommendations returns the recommendations for containers that
+// are over- or under-provisioned, or all of them if showAll is set.
+func flaggedRecommendations(recommendations []*recommendation, showAll bool) []*recommendation {
+	if showAll {
+		return recommendations
+	}
+
+	flagged := []*recommendation{}
+	for _, r := range recommendations {
+		if r.status != Provisioned {
+			flagged = append(flagged, r)
+		}
+	}
+	return flagged
+}
+
+// recommendResourceNames returns the requested resources that utilization
+// is reported for.
+func recommendResourceNames(opts Options) []corev1.ResourceName {
+	resourceNames := []corev1.ResourceName{}
+	for _, name := range opts.resourceNames() {
+		if _, ok := minRecommendedRequests[name]; ok {
+			resourceNames = append(resourceNames, name)
+		}
+	}
+	return resourceNames
+}
+
+// newRecommendation recommends a request of utilization * RequestHeadroom
+// and, for containers that already have a limit, a limit of utilization *
+// LimitHeadroom. A container is under-provisioned when its request or limit
+// falls short of the recommendation by more than Tolerance, and
+// over-provisioned when its request exceeds it by more than Tolerance.
+func newRecommendation(name corev1.ResourceName, rm *ResourceMetric, opts Options) *recommendation {
+	r := &recommendation{
+		resourceName: name,
+		utilization:  rm.utilization,
+		request:      rm.request,
+		limit:        rm.limit,
+		status:       Provisioned,
+	}
+
+	r.recommendedRequest = scaleQuantity(name, rm.utilization, opts.RequestHeadroom)
+	if minRequest := minRecommendedRequests[name]; r.recommendedRequest.Cmp(minRequest) < 0 {
+		r.recommendedRequest = minRequest.DeepCopy()
+	}
+
+	if !rm.limit.IsZero() {
+		r.recommendedLimit = scaleQuantity(name, rm.utilization, opts.LimitHeadroom)
+		if r.recommendedLimit.Cmp(r.recommendedRequest) < 0 {
+			r.recommendedLimit = r.recommendedRequest.DeepCopy()
+		}
+	}
+
+	switch {
+	case quantityRatio(r.request, r.recommendedRequest) < 1-opts.Tolerance,
+		!r.limit.IsZero() && quantityRatio(r.limit, r.recommendedLimit) < 1-opts.Tolerance:
+		r.status = UnderProvisioned
+	case quantityRatio(r.request, r.recommendedRequest) > 1+opts.Tolerance:
+		r.status = OverProvisioned
+	}
+
+	return r
+}
+
+// scaleQuantity multiplies a quantity by ratio, rounding up to whole
+// millicores for CPU and whole Mebibytes for memory.
+func scaleQuantity(name corev1.ResourceName, q resource.Quantity, ratio float64) resource.Quantity {
+	if name == corev1.ResourceCPU {
+		return *resource.NewMilliQuantity(int64(math.Ceil(float64(q.MilliValue())*ratio)), resource.DecimalSI)
+	}
+	mebibytes := int64(math.Ceil(float64(q.Value()) * ratio / Mebibyte))
+	return *resource.NewQuantity(mebibytes*Mebibyte, resource.BinarySI)
+}
+
+func quantityRatio(actual, target resource.Quantity) float64 {
+	if target.IsZero() {
+		return 1
+	}
+	return float64(actual.MilliValue()) / float64(target.MilliValue())
+}
+
+func recommendationString(name corev1.ResourceName, q resource.Quantity) string {
+	if name == corev1.ResourceCPU {
+		return fmt.Sprintf("%dm", q.MilliValue())
+	}
+	return fmt.Sprintf("%dMi", formatToMegiBytes(q))
+}
+
+// limitStrings returns the current and recommended limit, or VoidValue for
+// both if the container has no limit.
+func (r *recommendation) limitStrings() (string, string) {
+	if r.limit.IsZero() {
+		return VoidValue, VoidValue
+	}
+	return recommendationString(r.resourceName, r.limit), recommendationString(r.resourceName, r.recommendedLimit)
+}
+
+func printRecommendations(recommendations []*recommendation, opts Options) error {
+	switch opts.OutputFormat {
+	case TableOutput:
+		printRecommendationTable(flaggedRecommendations(recommendations, opts.ShowAllRecommendations), opts)
+	case JSONOutput, YAMLOutput:
+		printRecommendationList(flaggedRecommendations(recommendations, opts.ShowAllRecommendations), opts.OutputFormat)
+	case PatchOutput:
+		patches, err := buildRecommendationPatches(recommendations, opts.ShowAllRecommendations)
+		if err != nil {
+			return fmt.Errorf("building patches: %w", err)
+		}
+		fmt.Print(patches)
+	default:
+		return fmt.Errorf("unsupported output type: %s", opts.OutputFormat)
+	}
+	return nil
+}
+
+func printRecommendationTable(recommendations []*recommendation, opts Options) {
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 2, ' ', 0)
+
+	printLine := func(namespace string, items ...string) {
+		if opts.Namespace == "" {
+			items = append([]string{namespace}, items...)
+		}
+		_, _ = fmt.Fprintln(w, strings.Join(items, "\t "))
+	}
+
+	printLine("NAMESPACE", "POD", "CONTAINER", "RESOURCE", "UTIL", "REQUEST", "RECOMMENDED REQUEST", "LIMIT", "RECOMMENDED LIMIT", "STATUS")
+	for _, r := range recommendations {
+		limit, recommendedLimit := r.limitStrings()
+		printLine(r.namespace,
+			r.pod,
+			r.container,
+			resourceDisplayName(r.resourceName),
+			recommendationString(r.resourceName, r.utilization),
+			recommendationString(r.resourceName, r.request),
+			recommendationString(r.resourceName, r.recommendedRequest),
+			limit,
+			recommendedLimit,
+			r.status,
+		)
+	}
+
+	err := w.Flush()
+	if err != nil {
+		fmt.Printf("Error writing to table: %s", err)
+	}
+}
+
+func printRecommendationList(recommendations []*recommendation, outputType string) {
+	var response listRecommendations
+	response.Recommendations = []*listRecommendation{}
+
+	for _, r := range recommendations {
+		lr := &listRecommendation{
+			Namespace:          r.namespace,
+			Pod:                r.pod,
+			Container:          r.container,
+			Workload:           fmt.Sprintf("%s/%s", r.workload.kind, r.workload.name),
+			Resource:           string(r.resourceName),
+			Status:             r.status,
+			Utilization:        recommendationString(r.resourceName, r.utilization),
+			Request:            recommendationString(r.resourceName, r.request),
+			RecommendedRequest: recommendationString(r.resourceName, r.recommendedRequest),
+		}
+		if !r.limit.IsZero() {
+			lr.Limit, lr.RecommendedLimit = r.limitStrings()
+		}
+		response.Recommendations = append(response.Recommendations, lr)
+	}
+
+	jsonRaw, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		fmt.Println("Error Marshalling JSON")
+		fmt.Println(err)
+		return
+	}
+
+	if outputType == JSONOutput {
+		fmt.Printf("%s", jsonRaw)
+		return
+	}
+
+	yamlRaw, err := yaml.JSONToYAML(jsonRaw)
+	if err != nil {
+		fmt.Println("Error Converting JSON to Yaml")
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%s", yamlRaw)
+}
+
+// buildRecommendationPatches returns one strategic merge patch per workload
+// that `kubectl patch` can apply, covering the containers that are flagged
+// in any replica, or every container if showAll is set. When replicas of a
+// workload receive different recommendations, the largest is used. Pods
+// without a controller are left out, as their resources cannot be patched,
+// as are Jobs that are not owned by a CronJob, since a Job's pod template is
+// immutable.
+// Sidecars are patched under initContainers, where they are declared.
+func buildRecommendationPatches(recommendations []*recommendation, showAll bool) (string, error) {
+	type containerResources struct {
+		sidecar  bool
+		requests map[corev1.ResourceName]resource.Quantity
+		limits   map[corev1.ResourceName]resource.Quantity
+	}
+	type workloadPatch struct {
+		namespace  string
+		workload   workloadRef
+		containers map[string]*containerResources
+	}
+
+	setMax := func(m map[corev1.ResourceName]resource.Quantity, name corev1.ResourceName, q resource.Quantity) {
+		if current, ok := m[name]; !ok || q.Cmp(current) > 0 {
+			m[name] = q
+		}
+	}
+
+	containerKey := func(r *recommendation) string {
+		return workloadOwnerKey(r.namespace, r.workload) + "/" + r.container
+	}
+	included := map[string]bool{}
+	for _, r := range flaggedRecommendations(recommendations, showAll) {
+		included[containerKey(r)] = true
+	}
+
+	patches := map[string]*workloadPatch{}
+	for _, r := range recommendations {
+		if !included[containerKey(r)] || r.workload.kind == "Pod" || r.workload.kind == "Job" {
+			continue
+		}
+
+		key := workloadOwnerKey(r.namespace, r.workload)
+		patch := patches[key]
+		if patch == nil {
+			patch = &workloadPatch{
+				namespace:  r.namespace,
+				workload:   r.workload,
+				containers: map[string]*containerResources{},
+			}
+			patches[key] = patch
+		}
+
+		cr := patch.containers[r.container]
+		if cr == nil {
+			cr = &containerResources{
+				sidecar:  r.containerType == SidecarContainerType,
+				requests: map[corev1.ResourceName]resource.Quantity{},
+				limits:   map[corev1.ResourceName]resource.Quantity{},
+			}
+			patch.containers[r.container] = cr
+		}
+
+		setMax(cr.requests, r.resourceName, r.recommendedRequest)
+		if !r.recommendedLimit.IsZero() {
+			setMax(cr.limits, r.resourceName, r.recommendedLimit)
+		}
+	}
+
+	keys := make([]string, 0, len(patches))
+	for key := range patches {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		patch := patches[key]
+
+		containerNames := make([]string, 0, len(patch.containers))
+		for name := range patch.containers {
+			containerNames = append(containerNames, name)
+		}
+		sort.Strings(containerNames)
+
+		podSpec := map[string]interface{}{}
+		for _, name := range containerNames {
+			cr := patch.containers[name]
+			resources := map[string]interface{}{
+				"requests": quantityStrings(cr.requests),
+			}
+			if len(cr.limits) > 0 {
+				resources["limits"] = quantityStrings(cr.limits)
+			}
+			field := "containers"
+			if cr.sidecar {
+				field = "initContainers"
+			}
+			containers, _ := podSpec[field].([]interface{})
+			podSpec[field] = append(containers, map[string]interface{}{
+				"name":      name,
+				"resources": resources,
+			})
+		}
+
+		spec := podSpecPatch(patch.workload.kind, podSpec)
+		jsonRaw, err := json.Marshal(spec)
+		if err != nil {
+			return "", err
+		}
+		yamlRaw, err := yaml.Marshal(spec)
+		if err != nil {
+			return "", err
+		}
+
+		// Each document is preceded by a command that applies it on its own.
+		_, _ = fmt.Fprintf(&sb, "---\n# kubectl patch %s %s --namespace %s --patch '%s'\n%s",
+			strings.ToLower(patch.workload.kind), patch.workload.name, patch.namespace, jsonRaw, yamlRaw)
+	}
+
+	return sb.String(), nil
+}
+
+// podSpecPatch nests a pod spec patch at the path the pod template lives at
+// for the given kind of workload.
+func podSpecPatch(kind string, podSpec map[string]interface{}) map[string]interface{} {
+	template := map[string]interface{}{
+		"spec": podSpec,
+	}
+
+	switch kind {
+	case "CronJob":
+		return map[string]interface{}{
+			"spec": map[string]interface{}{
+				"jobTemplate": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"template": template,
+					},
+				},
+			},
+		}
+	default:
+		return map[string]interface{}{
+			"spec": map[string]interface{}{
+				"template": template,
+			},
+		}
+	}
+}
+
+func quantityStrings(quantities map[corev1.ResourceName]resource.Quantity) map[string]string {
+	strs := map[string]string{}
+	for name, q := range quantities {
+		strs[string(name)] = recommendationString(name, q)
+	}
+	return strs
+}
@@ -0,0 +1,190 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+var testRecommendOptions = Options{
+	RequestHeadroom: 1.2,
+	LimitHeadroom:   1.5,
+	Tolerance:       0.25,
+}
+
+func TestNewRecommendation(t *testing.T) {
+	tests := []struct {
+		name               string
+		resourceName       corev1.ResourceName
+		utilization        string
+		request            string
+		limit              string
+		recommendedRequest string
+		recommendedLimit   string
+		status             string
+	}{
+		{"over-provisioned cpu", corev1.ResourceCPU, "20m", "2", "0", "24m", "0", OverProvisioned},
+		{"under-provisioned cpu", corev1.ResourceCPU, "500m", "100m", "0", "600m", "0", UnderProvisioned},
+		{"well provisioned cpu", corev1.ResourceCPU, "100m", "120m", "0", "120m", "0", Provisioned},
+		{"limit too low", corev1.ResourceCPU, "100m", "120m", "110m", "120m", "150m", UnderProvisioned},
+		{"idle container", corev1.ResourceCPU, "0", "10m", "0", "10m", "0", Provisioned},
+		{"over-provisioned memory", corev1.ResourceMemory, "100Mi", "1Gi", "2Gi", "120Mi", "150Mi", OverProvisioned},
+		{"memory rounds up", corev1.ResourceMemory, "10Mi", "0", "0", "16Mi", "0", UnderProvisioned},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newRecommendation(test.resourceName, &ResourceMetric{
+				resourceType: string(test.resourceName),
+				utilization:  resource.MustParse(test.utilization),
+				request:      resource.MustParse(test.request),
+				limit:        resource.MustParse(test.limit),
+			}, testRecommendOptions)
+
+			recommendedRequest := resource.MustParse(test.recommendedRequest)
+			recommendedLimit := resource.MustParse(test.recommendedLimit)
+			assert.Equal(t, 0, r.recommendedRequest.Cmp(recommendedRequest), "recommended request %s", r.recommendedRequest.String())
+			assert.Equal(t, 0, r.recommendedLimit.Cmp(recommendedLimit), "recommended limit %s", r.recommendedLimit.String())
+			assert.Equal(t, test.status, r.status)
+		})
+	}
+}
+
+func TestBuildRecommendations(t *testing.T) {
+	cm := getRecommendTestClusterMetric()
+
+	recommendations := buildRecommendations(&cm, testRecommendOptions)
+	assert.Len(t, recommendations, 4)
+	assert.Equal(t, Provisioned, recommendations[3].status)
+
+	recommendations = flaggedRecommendations(recommendations, false)
+	assert.Len(t, recommendations, 3)
+	assert.Equal(t, "web-1", recommendations[0].pod)
+	assert.Equal(t, corev1.ResourceCPU, recommendations[0].resourceName)
+	assert.Equal(t, OverProvisioned, recommendations[0].status)
+	assert.Equal(t, "web-1", recommendations[1].pod)
+	assert.Equal(t, corev1.ResourceMemory, recommendations[1].resourceName)
+	assert.Equal(t, "web-2", recommendations[2].pod)
+	assert.Equal(t, workloadRef{kind: "Deployment", name: "web"}, recommendations[2].workload)
+
+	opts := testRecommendOptions
+	opts.Resources = []string{"cpu", "nvidia.com/gpu"}
+	assert.Len(t, buildRecommendations(&cm, opts), 2)
+
+	// Containers without metrics are not recommended for.
+	cm.nodeMetrics["node-1"].podMetrics["default-web-1"].containerMetrics["app"].measured = false
+	recommendations = buildRecommendations(&cm, testRecommendOptions)
+	assert.Len(t, recommendations, 2)
+	assert.Equal(t, "web-2", recommendations[0].pod)
+}
+
+func TestBuildRecommendationPatches(t *testing.T) {
+	cm := getRecommendTestClusterMetric()
+
+	// web-2 is not flagged for memory, but needs more than web-1.
+	patches, err := buildRecommendationPatches(buildRecommendations(&cm, testRecommendOptions), false)
+	assert.NoError(t, err)
+	assert.Equal(t, `---
+# kubectl patch deployment web --namespace default --patch '{"spec":{"template":{"spec":{"containers":[{"name":"app","resources":{"limits":{"cpu":"300m","memory":"330Mi"},"requests":{"cpu":"240m","memory":"264Mi"}}}]}}}}'
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        resources:
+          limits:
+            cpu: 300m
+            memory: 330Mi
+          requests:
+            cpu: 240m
+            memory: 264Mi
+`, patches)
+
+	assert.Contains(t, podSpecPatch("CronJob", nil)["spec"], "jobTemplate")
+
+	// Sidecars are declared under initContainers.
+	recommendations := buildRecommendations(&cm, testRecommendOptions)
+	for _, r := range recommendations {
+		assert.Equal(t, AppContainerType, r.containerType)
+		r.containerType = SidecarContainerType
+	}
+	patches, err = buildRecommendationPatches(recommendations, false)
+	assert.NoError(t, err)
+	assert.Contains(t, patches, `{"spec":{"template":{"spec":{"initContainers":[{"name":"app",`)
+	assert.NotContains(t, patches, "\n      containers:")
+
+	// The resources of pods without a controller cannot be patched.
+	recommendations = buildRecommendations(&cm, testRecommendOptions)
+	for _, r := range recommendations {
+		r.workload = workloadRef{kind: "Pod", name: r.pod}
+	}
+	patches, err = buildRecommendationPatches(recommendations, true)
+	assert.NoError(t, err)
+	assert.Empty(t, patches)
+
+	// Nor can those of pods owned by a Job, as its pod template is immutable.
+	recommendations = buildRecommendations(&cm, testRecommendOptions)
+	for _, r := range recommendations {
+		r.workload = workloadRef{kind: "Job", name: "migrate"}
+	}
+	patches, err = buildRecommendationPatches(recommendations, true)
+	assert.NoError(t, err)
+	assert.Empty(t, patches)
+}
+
+func getRecommendTestClusterMetric() ClusterMetric {
+	podList := getGroupTestPodList()
+	podList.Items = podList.Items[:2]
+	controller := true
+	for i := range podList.Items {
+		podList.Items[i].OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-7c9f8d", Controller: &controller}}
+	}
+
+	usage := func(name, cpu, memory string) v1beta1.PodMetrics {
+		return v1beta1.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Containers: []v1beta1.ContainerMetrics{
+				{
+					Name: "app",
+					Usage: corev1.ResourceList{
+						"cpu":    resource.MustParse(cpu),
+						"memory": resource.MustParse(memory),
+					},
+				},
+			},
+		}
+	}
+
+	// web-1 requests 200m and 256Mi, web-2 requests 400m and 256Mi.
+	pmList := &v1beta1.PodMetricsList{
+		Items: []v1beta1.PodMetrics{
+			usage("web-1", "20m", "100Mi"),
+			usage("web-2", "200m", "220Mi"),
+		},
+	}
+
+	cm := buildClusterMetric(podList, pmList, getGroupTestNodeList(), nil, defaultResourceNames)
+	cm.resolveWorkloads(map[string]workloadRef{
+		"default/ReplicaSet/web-7c9f8d": {kind: "Deployment", name: "web"},
+	})
+	return cm
+}
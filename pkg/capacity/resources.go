@@ -17,6 +17,7 @@ package capacity
 import (
 	"fmt"
 	"sort"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -32,54 +33,134 @@ var SupportedSortAttributes = [...]string{
 	"mem.util",
 	"mem.request",
 	"mem.limit",
+	"cpu.util.avg",
+	"cpu.util.max",
+	"cpu.util.p95",
+	"mem.util.avg",
+	"mem.util.max",
+	"mem.util.p95",
 	"cpu.util.percentage",
 	"cpu.request.percentage",
 	"cpu.limit.percentage",
 	"mem.util.percentage",
 	"mem.request.percentage",
 	"mem.limit.percentage",
+	"storage.request",
+	"storage.limit",
+	"storage.request.percentage",
+	"storage.limit.percentage",
 	"pod.count",
 	"name",
 }
 
+// Kibibyte represents the number of bytes in a kibibyte.
+const Kibibyte = 1024
+
 // Mebibyte represents the number of bytes in a mebibyte.
 const Mebibyte = 1024 * 1024
 
-type resourceMetric struct {
+// Gibibyte represents the number of bytes in a gibibyte.
+const Gibibyte = 1024 * 1024 * 1024
+
+// defaultResourceNames are the resources reported on when no others have
+// been requested.
+var defaultResourceNames = []corev1.ResourceName{
+	corev1.ResourceCPU,
+	corev1.ResourceMemory,
+}
+
+// resourceAliases maps the short names accepted by --resources and used as
+// sort attribute prefixes to the resource names they refer to. Any other
+// name is treated as a resource name, e.g. "nvidia.com/gpu.request".
+var resourceAliases = map[string]corev1.ResourceName{
+	"cpu":     corev1.ResourceCPU,
+	"mem":     corev1.ResourceMemory,
+	"storage": corev1.ResourceEphemeralStorage,
+}
+
+// ResourceMetric holds the allocatable amount, utilization, requests and
+// limits of a resource
+type ResourceMetric struct {
 	resourceType string
 	allocatable  resource.Quantity
 	utilization  resource.Quantity
 	request      resource.Quantity
 	limit        resource.Quantity
+	// samples holds the utilization at each sample when utilization has
+	// been sampled more than once.
+	samples []resource.Quantity
 }
 
-type clusterMetric struct {
-	cpu         *resourceMetric
-	memory      *resourceMetric
-	nodeMetrics map[string]*nodeMetric
+// ClusterMetric holds the resources of a cluster and each of its nodes
+type ClusterMetric struct {
+	resources   map[corev1.ResourceName]*ResourceMetric
+	nodeMetrics map[string]*NodeMetric
+	unscheduled *NodeMetric
 	podCount    *podCount
+	// classes holds the scheduled pods of the cluster grouped by QoS or
+	// priority class when grouping by either.
+	classes map[string]*NodeMetric
 }
 
-type nodeMetric struct {
+// NodeMetric holds the resources of a node and each pod scheduled to it
+type NodeMetric struct {
 	name       string
-	cpu        *resourceMetric
-	memory     *resourceMetric
-	podMetrics map[string]*podMetric
+	labels     map[string]string
+	resources  map[corev1.ResourceName]*ResourceMetric
+	podMetrics map[string]*PodMetric
 	podCount   *podCount
-}
-
-type podMetric struct {
-	name             string
-	namespace        string
-	cpu              *resourceMetric
-	memory           *resourceMetric
-	containerMetrics map[string]*containerMetric
-}
+	// classes holds the pods of the node grouped by QoS or priority class
+	// when grouping by either.
+	classes map[string]*NodeMetric
+}
+
+// PodMetric holds the resources of a pod and each of its containers
+type PodMetric struct {
+	name          string
+	namespace     string
+	pendingReason string
+	workload      workloadRef
+	qosClass      string
+	priorityClass string
+	resources     map[corev1.ResourceName]*ResourceMetric
+	// containerMetrics holds every container of the pod, including init and
+	// ephemeral containers, and its pod overhead if it has any.
+	containerMetrics map[string]*ContainerMetric
+}
+
+// ContainerMetric holds the resources of a container
+type ContainerMetric struct {
+	name          string
+	containerType string
+	resources     map[corev1.ResourceName]*ResourceMetric
+	// measured is true if utilization was reported for the container.
+	measured bool
+}
+
+const (
+	//AppContainerType is the constant value for the type of regular containers
+	AppContainerType = "container"
+	//InitContainerType is the constant value for the type of init containers
+	InitContainerType = "init"
+	//SidecarContainerType is the constant value for the type of init containers
+	//with a restartPolicy of Always, which run alongside regular containers
+	SidecarContainerType = "sidecar"
+	//EphemeralContainerType is the constant value for the type of ephemeral
+	//containers, such as those added by kubectl debug
+	EphemeralContainerType = "ephemeral"
+	//OverheadContainerType is the constant value for the type of the line
+	//holding the pod overhead added by a RuntimeClass
+	OverheadContainerType = "overhead"
+)
 
-type containerMetric struct {
-	name   string
-	cpu    *resourceMetric
-	memory *resourceMetric
+// containerTypeOrder is the order in which containers of each type are
+// listed.
+var containerTypeOrder = map[string]int{
+	InitContainerType:      0,
+	SidecarContainerType:   1,
+	AppContainerType:       2,
+	EphemeralContainerType: 3,
+	OverheadContainerType:  4,
 }
 
 type podCount struct {
@@ -88,158 +169,239 @@ type podCount struct {
 }
 
 func buildClusterMetric(podList *corev1.PodList, pmList *v1beta1.PodMetricsList,
-	nodeList *corev1.NodeList, nmList *v1beta1.NodeMetricsList) clusterMetric {
-	cm := clusterMetric{
-		cpu:         &resourceMetric{resourceType: "cpu"},
-		memory:      &resourceMetric{resourceType: "memory"},
-		nodeMetrics: map[string]*nodeMetric{},
+	nodeList *corev1.NodeList, nmList *v1beta1.NodeMetricsList, resourceNames []corev1.ResourceName) ClusterMetric {
+	cm := newClusterMetric(nodeList, nmList, resourceNames)
+
+	podMetrics := podMetricsByKey(pmList)
+	for i := range podList.Items {
+		cm.addPod(&podList.Items[i], podMetrics)
+	}
+
+	cm.addNodeTotals(nmList == nil)
+	return cm
+}
+
+// newClusterMetric returns a ClusterMetric with each of the given nodes and
+// their utilization, if known, that pods can then be added to as they are
+// listed. Node totals must be added with addNodeTotals once every pod has
+// been added.
+func newClusterMetric(nodeList *corev1.NodeList, nmList *v1beta1.NodeMetricsList, resourceNames []corev1.ResourceName) ClusterMetric {
+	cm := ClusterMetric{
+		resources:   newResourceMetrics(resourceNames, nil),
+		nodeMetrics: map[string]*NodeMetric{},
 		podCount:    &podCount{},
 	}
 
-	var totalPodAllocatable int64
-	var totalPodCurrent int64
 	for _, node := range nodeList.Items {
-		var tmpPodCount int64
-		for _, pod := range podList.Items {
-			if pod.Spec.NodeName == node.Name && pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
-				tmpPodCount++
-			}
-		}
-		totalPodCurrent += tmpPodCount
-		totalPodAllocatable += node.Status.Allocatable.Pods().Value()
-		cm.nodeMetrics[node.Name] = &nodeMetric{
-			name: node.Name,
-			cpu: &resourceMetric{
-				resourceType: "cpu",
-				allocatable:  node.Status.Allocatable["cpu"],
-			},
-			memory: &resourceMetric{
-				resourceType: "memory",
-				allocatable:  node.Status.Allocatable["memory"],
-			},
-			podMetrics: map[string]*podMetric{},
+		cm.podCount.allocatable += node.Status.Allocatable.Pods().Value()
+		cm.nodeMetrics[node.Name] = &NodeMetric{
+			name:       node.Name,
+			labels:     node.Labels,
+			resources:  newResourceMetrics(resourceNames, node.Status.Allocatable),
+			podMetrics: map[string]*PodMetric{},
 			podCount: &podCount{
-				current:     tmpPodCount,
 				allocatable: node.Status.Allocatable.Pods().Value(),
 			},
 		}
 	}
 
-	cm.podCount.current = totalPodCurrent
-	cm.podCount.allocatable = totalPodAllocatable
-
 	if nmList != nil {
 		for _, nm := range nmList.Items {
 			if cm.nodeMetrics[nm.Name] == nil {
 				continue
 			}
-			cm.nodeMetrics[nm.Name].cpu.utilization = nm.Usage["cpu"]
-			cm.nodeMetrics[nm.Name].memory.utilization = nm.Usage["memory"]
+			for name, rm := range cm.nodeMetrics[nm.Name].resources {
+				rm.utilization = nm.Usage[name]
+			}
 		}
 	}
 
+	return cm
+}
+
+// podMetricsByKey returns pod metrics keyed by the namespace and name of the
+// pod they belong to.
+func podMetricsByKey(pmList *v1beta1.PodMetricsList) map[string]v1beta1.PodMetrics {
 	podMetrics := map[string]v1beta1.PodMetrics{}
 	if pmList != nil {
 		for _, pm := range pmList.Items {
 			podMetrics[fmt.Sprintf("%s-%s", pm.GetNamespace(), pm.GetName())] = pm
 		}
 	}
+	return podMetrics
+}
 
-	for _, pod := range podList.Items {
-		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
-			cm.addPodMetric(&pod, podMetrics[fmt.Sprintf("%s-%s", pod.GetNamespace(), pod.GetName())])
-		}
+// addPod adds a pod that is running or pending, along with its metrics, to
+// the node it has been scheduled to. Pods that have not been scheduled yet
+// are collected into a synthetic node so their demand can be reported
+// alongside real nodes. They are not included in cluster totals. Pods
+// scheduled to nodes that are not part of the ClusterMetric are ignored.
+func (cm *ClusterMetric) addPod(pod *corev1.Pod, podMetrics map[string]v1beta1.PodMetrics) {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return
 	}
 
-	for _, node := range nodeList.Items {
-		if nm, ok := cm.nodeMetrics[node.Name]; ok {
-			cm.addNodeMetric(nm)
-			// When namespace filtering is configured, we want to sum pod
-			// utilization instead of relying on node util.
-			if nmList == nil {
-				nm.addPodUtilization()
+	if pod.Spec.NodeName == "" {
+		if cm.unscheduled == nil {
+			cm.unscheduled = &NodeMetric{
+				name:       UnscheduledNodeName,
+				resources:  newResourceMetrics(cm.ResourceNames(), nil),
+				podMetrics: map[string]*PodMetric{},
+				podCount:   &podCount{},
 			}
 		}
+		cm.unscheduled.podCount.current++
+	} else if nm, ok := cm.nodeMetrics[pod.Spec.NodeName]; ok {
+		nm.podCount.current++
+		cm.podCount.current++
 	}
 
-	return cm
+	cm.addPodMetric(pod, podMetrics[fmt.Sprintf("%s-%s", pod.GetNamespace(), pod.GetName())])
+}
+
+// addNodeTotals adds the totals of each node to the cluster. When pod
+// utilization is summed, such as when filtering by namespace, node
+// utilization is the total of its pods rather than the node's own.
+func (cm *ClusterMetric) addNodeTotals(sumPodUtilization bool) {
+	for _, nm := range cm.nodeMetrics {
+		cm.addNodeMetric(nm)
+		if sumPodUtilization {
+			nm.addPodUtilization()
+		}
+	}
+}
+
+// newResourceMetrics returns an empty ResourceMetric for each of the given
+// resource names, with allocatable populated from the provided list.
+func newResourceMetrics(resourceNames []corev1.ResourceName, allocatable corev1.ResourceList) map[corev1.ResourceName]*ResourceMetric {
+	resources := make(map[corev1.ResourceName]*ResourceMetric, len(resourceNames))
+	for _, name := range resourceNames {
+		resources[name] = &ResourceMetric{
+			resourceType: string(name),
+			allocatable:  allocatable[name],
+		}
+	}
+	return resources
 }
 
-func (rm *resourceMetric) addMetric(m *resourceMetric) {
+func (rm *ResourceMetric) addMetric(m *ResourceMetric) {
 	rm.allocatable.Add(m.allocatable)
 	rm.utilization.Add(m.utilization)
 	rm.request.Add(m.request)
 	rm.limit.Add(m.limit)
+	rm.addSamples(m.samples)
 }
 
-func (cm *clusterMetric) addPodMetric(pod *corev1.Pod, podMetrics v1beta1.PodMetrics) {
+func (cm *ClusterMetric) addPodMetric(pod *corev1.Pod, podMetrics v1beta1.PodMetrics) {
 	req, limit := resourcehelper.PodRequestsAndLimits(pod)
 	key := fmt.Sprintf("%s-%s", pod.Namespace, pod.Name)
 	nm := cm.nodeMetrics[pod.Spec.NodeName]
+	if pod.Spec.NodeName == "" {
+		nm = cm.unscheduled
+	}
 
-	pm := &podMetric{
-		name:      pod.Name,
-		namespace: pod.Namespace,
-		cpu: &resourceMetric{
-			resourceType: "cpu",
-			request:      req["cpu"],
-			limit:        limit["cpu"],
-		},
-		memory: &resourceMetric{
-			resourceType: "memory",
-			request:      req["memory"],
-			limit:        limit["memory"],
-		},
-		containerMetrics: map[string]*containerMetric{},
+	pm := &PodMetric{
+		name:             pod.Name,
+		namespace:        pod.Namespace,
+		pendingReason:    pendingReason(pod),
+		workload:         podWorkload(pod),
+		qosClass:         string(pod.Status.QOSClass),
+		priorityClass:    pod.Spec.PriorityClassName,
+		resources:        map[corev1.ResourceName]*ResourceMetric{},
+		containerMetrics: map[string]*ContainerMetric{},
+	}
+
+	for name := range cm.resources {
+		pm.resources[name] = &ResourceMetric{
+			resourceType: string(name),
+			request:      req[name],
+			limit:        limit[name],
+		}
+	}
+
+	addContainerMetric := func(name, containerType string, requests, limits corev1.ResourceList) {
+		ctm := &ContainerMetric{
+			name:          name,
+			containerType: containerType,
+			resources:     map[corev1.ResourceName]*ResourceMetric{},
+		}
+		for resourceName := range cm.resources {
+			ctm.resources[resourceName] = &ResourceMetric{
+				resourceType: string(resourceName),
+				request:      requests[resourceName],
+				limit:        limits[resourceName],
+			}
+			if nm != nil {
+				ctm.resources[resourceName].allocatable = nm.resources[resourceName].allocatable
+			}
+		}
+		pm.containerMetrics[name] = ctm
 	}
 
 	for _, container := range pod.Spec.Containers {
-		pm.containerMetrics[container.Name] = &containerMetric{
-			name: container.Name,
-			cpu: &resourceMetric{
-				resourceType: "cpu",
-				request:      container.Resources.Requests["cpu"],
-				limit:        container.Resources.Limits["cpu"],
-				allocatable:  nm.cpu.allocatable,
-			},
-			memory: &resourceMetric{
-				resourceType: "memory",
-				request:      container.Resources.Requests["memory"],
-				limit:        container.Resources.Limits["memory"],
-				allocatable:  nm.memory.allocatable,
-			},
+		addContainerMetric(container.Name, AppContainerType, container.Resources.Requests, container.Resources.Limits)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		containerType := InitContainerType
+		if container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			containerType = SidecarContainerType
 		}
+		addContainerMetric(container.Name, containerType, container.Resources.Requests, container.Resources.Limits)
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		addContainerMetric(container.Name, EphemeralContainerType, container.Resources.Requests, container.Resources.Limits)
+	}
+	if len(pod.Spec.Overhead) > 0 {
+		// Overhead is only added to the limits of resources the pod is
+		// limited on, as it is by PodRequestsAndLimits.
+		overheadLimits := corev1.ResourceList{}
+		for name, quantity := range pod.Spec.Overhead {
+			if value, ok := limit[name]; ok && !value.IsZero() {
+				overheadLimits[name] = quantity
+			}
+		}
+		addContainerMetric(PodOverheadName, OverheadContainerType, pod.Spec.Overhead, overheadLimits)
 	}
 
 	if nm != nil {
 		nm.podMetrics[key] = pm
-		nm.podMetrics[key].cpu.allocatable = nm.cpu.allocatable
-		nm.podMetrics[key].memory.allocatable = nm.memory.allocatable
-
-		nm.cpu.request.Add(req["cpu"])
-		nm.cpu.limit.Add(limit["cpu"])
-		nm.memory.request.Add(req["memory"])
-		nm.memory.limit.Add(limit["memory"])
+		for name, rm := range nm.resources {
+			pm.resources[name].allocatable = rm.allocatable
+			rm.request.Add(req[name])
+			rm.limit.Add(limit[name])
+		}
 	}
 
 	for _, container := range podMetrics.Containers {
-		cm := pm.containerMetrics[container.Name]
-		if cm != nil {
-			pm.containerMetrics[container.Name].cpu.utilization = container.Usage["cpu"]
-			pm.cpu.utilization.Add(container.Usage["cpu"])
-			pm.containerMetrics[container.Name].memory.utilization = container.Usage["memory"]
-			pm.memory.utilization.Add(container.Usage["memory"])
+		if ctm := pm.containerMetrics[container.Name]; ctm != nil {
+			ctm.measured = true
+			for name, rm := range ctm.resources {
+				rm.utilization = container.Usage[name]
+				pm.resources[name].utilization.Add(container.Usage[name])
+			}
 		}
 	}
 }
 
-func (cm *clusterMetric) addNodeMetric(nm *nodeMetric) {
-	cm.cpu.addMetric(nm.cpu)
-	cm.memory.addMetric(nm.memory)
+// pendingReason returns the reason the scheduler gave for not scheduling a
+// pod, or an empty string if it has not reported one.
+func pendingReason(pod *corev1.Pod) string {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse {
+			return condition.Reason
+		}
+	}
+	return ""
 }
 
-func (cm *clusterMetric) getSortedNodeMetrics(sortBy string) []*nodeMetric {
-	sortedNodeMetrics := make([]*nodeMetric, len(cm.nodeMetrics))
+func (cm *ClusterMetric) addNodeMetric(nm *NodeMetric) {
+	for name, rm := range cm.resources {
+		rm.addMetric(nm.resources[name])
+	}
+}
+
+func (cm *ClusterMetric) getSortedNodeMetrics(sortBy string) []*NodeMetric {
+	sortedNodeMetrics := make([]*NodeMetric, len(cm.nodeMetrics))
 
 	i := 0
 	for name := range cm.nodeMetrics {
@@ -251,43 +413,20 @@ func (cm *clusterMetric) getSortedNodeMetrics(sortBy string) []*nodeMetric {
 		m1 := sortedNodeMetrics[i]
 		m2 := sortedNodeMetrics[j]
 
-		switch sortBy {
-		case "cpu.util":
-			return m2.cpu.utilization.MilliValue() < m1.cpu.utilization.MilliValue()
-		case "cpu.limit":
-			return m2.cpu.limit.MilliValue() < m1.cpu.limit.MilliValue()
-		case "cpu.request":
-			return m2.cpu.request.MilliValue() < m1.cpu.request.MilliValue()
-		case "mem.util":
-			return m2.memory.utilization.Value() < m1.memory.utilization.Value()
-		case "mem.limit":
-			return m2.memory.limit.Value() < m1.memory.limit.Value()
-		case "mem.request":
-			return m2.memory.request.Value() < m1.memory.request.Value()
-		case "cpu.util.percentage":
-			return m2.cpu.percent(m2.cpu.utilization) < m1.cpu.percent(m1.cpu.utilization)
-		case "cpu.limit.percentage":
-			return m2.cpu.percent(m2.cpu.limit) < m1.cpu.percent(m1.cpu.limit)
-		case "cpu.request.percentage":
-			return m2.cpu.percent(m2.cpu.request) < m1.cpu.percent(m1.cpu.request)
-		case "mem.util.percentage":
-			return m2.memory.percent(m2.memory.utilization) < m1.memory.percent(m1.memory.utilization)
-		case "mem.limit.percentage":
-			return m2.memory.percent(m2.memory.limit) < m1.memory.percent(m1.memory.limit)
-		case "mem.request.percentage":
-			return m2.memory.percent(m2.memory.request) < m1.memory.percent(m1.memory.request)
-		case "pod.count":
+		if sortBy == "pod.count" {
 			return m2.podCount.current < m1.podCount.current
-		default:
-			return m1.name < m2.name
 		}
+		if less, ok := resourceLess(sortBy, m1.resources, m2.resources); ok {
+			return less
+		}
+		return m1.name < m2.name
 	})
 
 	return sortedNodeMetrics
 }
 
-func (nm *nodeMetric) getSortedPodMetrics(sortBy string) []*podMetric {
-	sortedPodMetrics := make([]*podMetric, len(nm.podMetrics))
+func (nm *NodeMetric) getSortedPodMetrics(sortBy string) []*PodMetric {
+	sortedPodMetrics := make([]*PodMetric, len(nm.podMetrics))
 
 	i := 0
 	for name := range nm.podMetrics {
@@ -299,48 +438,25 @@ func (nm *nodeMetric) getSortedPodMetrics(sortBy string) []*podMetric {
 		m1 := sortedPodMetrics[i]
 		m2 := sortedPodMetrics[j]
 
-		switch sortBy {
-		case "cpu.util":
-			return m2.cpu.utilization.MilliValue() < m1.cpu.utilization.MilliValue()
-		case "cpu.limit":
-			return m2.cpu.limit.MilliValue() < m1.cpu.limit.MilliValue()
-		case "cpu.request":
-			return m2.cpu.request.MilliValue() < m1.cpu.request.MilliValue()
-		case "mem.util":
-			return m2.memory.utilization.Value() < m1.memory.utilization.Value()
-		case "mem.limit":
-			return m2.memory.limit.Value() < m1.memory.limit.Value()
-		case "mem.request":
-			return m2.memory.request.Value() < m1.memory.request.Value()
-		case "cpu.util.percentage":
-			return m2.cpu.percent(m2.cpu.utilization) < m1.cpu.percent(m1.cpu.utilization)
-		case "cpu.limit.percentage":
-			return m2.cpu.percent(m2.cpu.limit) < m1.cpu.percent(m1.cpu.limit)
-		case "cpu.request.percentage":
-			return m2.cpu.percent(m2.cpu.request) < m1.cpu.percent(m1.cpu.request)
-		case "mem.util.percentage":
-			return m2.memory.percent(m2.memory.utilization) < m1.memory.percent(m1.memory.utilization)
-		case "mem.limit.percentage":
-			return m2.memory.percent(m2.memory.limit) < m1.memory.percent(m1.memory.limit)
-		case "mem.request.percentage":
-			return m2.memory.percent(m2.memory.request) < m1.memory.percent(m1.memory.request)
-		default:
-			return m1.name < m2.name
+		if less, ok := resourceLess(sortBy, m1.resources, m2.resources); ok {
+			return less
 		}
+		return m1.name < m2.name
 	})
 
 	return sortedPodMetrics
 }
 
-func (nm *nodeMetric) addPodUtilization() {
+func (nm *NodeMetric) addPodUtilization() {
 	for _, pm := range nm.podMetrics {
-		nm.cpu.utilization.Add(pm.cpu.utilization)
-		nm.memory.utilization.Add(pm.memory.utilization)
+		for name, rm := range nm.resources {
+			rm.utilization.Add(pm.resources[name].utilization)
+		}
 	}
 }
 
-func (pm *podMetric) getSortedContainerMetrics(sortBy string) []*containerMetric {
-	sortedContainerMetrics := make([]*containerMetric, len(pm.containerMetrics))
+func (pm *PodMetric) getSortedContainerMetrics(sortBy string) []*ContainerMetric {
+	sortedContainerMetrics := make([]*ContainerMetric, len(pm.containerMetrics))
 
 	i := 0
 	for name := range pm.containerMetrics {
@@ -352,36 +468,99 @@ func (pm *podMetric) getSortedContainerMetrics(sortBy string) []*containerMetric
 		m1 := sortedContainerMetrics[i]
 		m2 := sortedContainerMetrics[j]
 
-		switch sortBy {
-		case "cpu.util":
-			return m2.cpu.utilization.MilliValue() < m1.cpu.utilization.MilliValue()
-		case "cpu.limit":
-			return m2.cpu.limit.MilliValue() < m1.cpu.limit.MilliValue()
-		case "cpu.request":
-			return m2.cpu.request.MilliValue() < m1.cpu.request.MilliValue()
-		case "mem.util":
-			return m2.memory.utilization.Value() < m1.memory.utilization.Value()
-		case "mem.limit":
-			return m2.memory.limit.Value() < m1.memory.limit.Value()
-		case "mem.request":
-			return m2.memory.request.Value() < m1.memory.request.Value()
-		default:
-			return m1.name < m2.name
+		if less, ok := resourceLess(sortBy, m1.resources, m2.resources); ok {
+			return less
+		}
+		if m1.containerType != m2.containerType {
+			return containerTypeOrder[m1.containerType] < containerTypeOrder[m2.containerType]
 		}
+		return m1.name < m2.name
 	})
 
 	return sortedContainerMetrics
 }
 
-func (rm *resourceMetric) requestString(availableFormat bool) string {
+// parseResourceSortAttribute splits a sort attribute such as
+// "cpu.request.percentage" into the resource it refers to, the field to
+// compare and whether that field should be compared as a percentage of
+// allocatable. Sampled utilization is compared by a statistic such as
+// "cpu.util.p95". ok is false if sortBy is not a resource sort attribute.
+func parseResourceSortAttribute(sortBy string) (name corev1.ResourceName, field string, percentage bool, ok bool) {
+	attr := strings.TrimSuffix(sortBy, ".percentage")
+	percentage = attr != sortBy
+
+	for _, stat := range sampleStats {
+		if trimmed, found := strings.CutSuffix(attr, ".util."+stat); found && trimmed != "" {
+			attr = trimmed + ".util"
+			field = "util." + stat
+		}
+	}
+
+	i := strings.LastIndex(attr, ".")
+	if i < 1 {
+		return "", "", false, false
+	}
+
+	switch attr[i+1:] {
+	case "util", "request", "limit":
+		if field == "" {
+			field = attr[i+1:]
+		}
+	default:
+		return "", "", false, false
+	}
+
+	if alias, found := resourceAliases[attr[:i]]; found {
+		return alias, field, percentage, true
+	}
+	return corev1.ResourceName(attr[:i]), field, percentage, true
+}
+
+// resourceLess reports whether the metrics in m1 should be sorted before the
+// metrics in m2. Larger values sort first. ok is false if sortBy does not
+// refer to a resource present in both.
+func resourceLess(sortBy string, m1, m2 map[corev1.ResourceName]*ResourceMetric) (less bool, ok bool) {
+	name, field, percentage, ok := parseResourceSortAttribute(sortBy)
+	if !ok {
+		return false, false
+	}
+
+	rm1, rm2 := m1[name], m2[name]
+	if rm1 == nil || rm2 == nil {
+		return false, false
+	}
+
+	if percentage {
+		return rm2.percent(rm2.field(field)) < rm1.percent(rm1.field(field)), true
+	}
+	q1, q2 := rm1.field(field), rm2.field(field)
+	return q2.Cmp(q1) < 0, true
+}
+
+// field returns the quantity of a ResourceMetric identified by a sort
+// attribute field name.
+func (rm *ResourceMetric) field(field string) resource.Quantity {
+	switch field {
+	case "util":
+		return rm.utilization
+	case "util." + StatAvg, "util." + StatMax, "util." + StatP95:
+		return rm.utilizationStat(strings.TrimPrefix(field, "util."))
+	case "limit":
+		return rm.limit
+	default:
+		return rm.request
+	}
+}
+
+func (rm *ResourceMetric) requestString(availableFormat bool) string {
 	return resourceString(rm.resourceType, rm.request, rm.allocatable, availableFormat)
 }
 
-func (rm *resourceMetric) limitString(availableFormat bool) string {
+func (rm *ResourceMetric) limitString(availableFormat bool) string {
 	return resourceString(rm.resourceType, rm.limit, rm.allocatable, availableFormat)
 }
 
-func (rm *resourceMetric) utilString(availableFormat bool) string {
+func (rm *ResourceMetric) utilString(availableFormat bool) string {
 	return resourceString(rm.resourceType, rm.utilization, rm.allocatable, availableFormat)
 }
 
@@ -397,15 +576,16 @@ func resourceString(resourceType string, actual, allocatable resource.Quantity,
 	}
 
 	var actualStr, allocatableStr string
+	unit, unitBytes, isBytes := byteUnit(resourceType)
 
 	if availableFormat {
-		switch resourceType {
-		case "cpu":
+		switch {
+		case resourceType == "cpu":
 			actualStr = fmt.Sprintf("%dm", allocatable.MilliValue()-actual.MilliValue())
 			allocatableStr = fmt.Sprintf("%dm", allocatable.MilliValue())
-		case "memory":
-			actualStr = fmt.Sprintf("%dMi", formatToMegiBytes(allocatable)-formatToMegiBytes(actual))
-			allocatableStr = fmt.Sprintf("%dMi", formatToMegiBytes(allocatable))
+		case isBytes:
+			actualStr = fmt.Sprintf("%d%s", formatToUnit(allocatable, unitBytes)-formatToUnit(actual, unitBytes), unit)
+			allocatableStr = fmt.Sprintf("%d%s", formatToUnit(allocatable, unitBytes), unit)
 		default:
 			actualStr = fmt.Sprintf("%d", allocatable.Value()-actual.Value())
 			allocatableStr = fmt.Sprintf("%d", allocatable.Value())
@@ -414,11 +594,11 @@ func resourceString(resourceType string, actual, allocatable resource.Quantity,
 		return fmt.Sprintf("%s/%s", actualStr, allocatableStr)
 	}
 
-	switch resourceType {
-	case "cpu":
+	switch {
+	case resourceType == "cpu":
 		actualStr = fmt.Sprintf("%dm", actual.MilliValue())
-	case "memory":
-		actualStr = fmt.Sprintf("%dMi", formatToMegiBytes(actual))
+	case isBytes:
+		actualStr = fmt.Sprintf("%d%s", formatToUnit(actual, unitBytes), unit)
 	default:
 		actualStr = fmt.Sprintf("%d", actual.Value())
 	}
@@ -426,16 +606,60 @@ func resourceString(resourceType string, actual, allocatable resource.Quantity,
 	return fmt.Sprintf("%s (%d%%%%)", actualStr, int64(utilPercent))
 }
 
+// resourceDisplayName returns the name used for a resource in output headers.
+// Hugepage sizes keep their original case, e.g. "HUGEPAGES-2Mi".
+func resourceDisplayName(name corev1.ResourceName) string {
+	if name == corev1.ResourceCPU {
+		return "CPU"
+	}
+	if pageSize, found := strings.CutPrefix(string(name), corev1.ResourceHugePagesPrefix); found {
+		return strings.ToUpper(corev1.ResourceHugePagesPrefix) + pageSize
+	}
+	return strings.ToUpper(string(name))
+}
+
+// byteUnit returns the unit a resource measured in bytes is displayed in,
+// along with the number of bytes in that unit. Memory is shown in Mi,
+// ephemeral storage in Gi and hugepages in the unit of their page size.
+// isBytes is false for resources that are not measured in bytes.
+func byteUnit(resourceType string) (unit string, unitBytes int64, isBytes bool) {
+	switch {
+	case resourceType == "memory":
+		return "Mi", Mebibyte, true
+	case resourceType == "ephemeral-storage":
+		return "Gi", Gibibyte, true
+	case strings.HasPrefix(resourceType, corev1.ResourceHugePagesPrefix):
+		pageSize, err := resource.ParseQuantity(strings.TrimPrefix(resourceType, corev1.ResourceHugePagesPrefix))
+		switch {
+		case err != nil:
+			return "Mi", Mebibyte, true
+		case pageSize.Value() >= Gibibyte:
+			return "Gi", Gibibyte, true
+		case pageSize.Value() >= Mebibyte:
+			return "Mi", Mebibyte, true
+		default:
+			return "Ki", Kibibyte, true
+		}
+	default:
+		return "", 0, false
+	}
+}
+
 func formatToMegiBytes(actual resource.Quantity) int64 {
-	value := actual.Value() / Mebibyte
-	if actual.Value()%Mebibyte != 0 {
+	return formatToUnit(actual, Mebibyte)
+}
+
+// formatToUnit returns the number of units of unitBytes in actual, rounded up.
+func formatToUnit(actual resource.Quantity, unitBytes int64) int64 {
+	value := actual.Value() / unitBytes
+	if actual.Value()%unitBytes != 0 {
 		value++
 	}
 	return value
 }
 
 // NOTE: This might not be a great place for closures due to the cyclical nature of how resourceType works. Perhaps better implemented another way.
-func (rm resourceMetric) valueFunction() (f func(r resource.Quantity) string) {
+func (rm ResourceMetric) valueFunction() (f func(r resource.Quantity) string) {
 	switch rm.resourceType {
 	case "cpu":
 		f = func(r resource.Quantity) string {
@@ -445,19 +669,32 @@ func (rm resourceMetric) valueFunction() (f func(r resource.Quantity) string) {
 		f = func(r resource.Quantity) string {
 			return fmt.Sprintf("%dMi", formatToMegiBytes(r))
 		}
+	default:
+		if unit, unitBytes, isBytes := byteUnit(rm.resourceType); isBytes {
+			f = func(r resource.Quantity) string {
+				return fmt.Sprintf("%d%s", formatToUnit(r, unitBytes), unit)
+			}
+		} else {
+			f = func(r resource.Quantity) string {
+				return fmt.Sprintf("%d", r.Value())
+			}
+		}
 	}
 	return f
 }
 
 // NOTE: This might not be a great place for closures due to the cyclical nature of how resourceType works. Perhaps better implemented another way.
-func (rm resourceMetric) percentFunction() (f func(r resource.Quantity) string) {
+func (rm ResourceMetric) percentFunction() (f func(r resource.Quantity) string) {
 	f = func(r resource.Quantity) string {
 		return fmt.Sprintf("%v%%", rm.percent(r))
 	}
 	return f
 }
 
-func (rm resourceMetric) percent(r resource.Quantity) int64 {
+func (rm ResourceMetric) percent(r resource.Quantity) int64 {
+	if rm.allocatable.MilliValue() == 0 {
+		return 0
+	}
 	return int64(float64(r.MilliValue()) / float64(rm.allocatable.MilliValue()) * 100)
 }
 
@@ -465,10 +702,28 @@ func (rm resourceMetric) percent(r resource.Quantity) int64 {
 // -----------------------------------------
 
 func resourceCSVString(resourceType string, actual resource.Quantity) string {
-	if resourceType == "memory" {
+	switch resourceType {
+	case "cpu":
+		return fmt.Sprintf("%d", actual.MilliValue())
+	case "memory":
 		return fmt.Sprintf("%d", formatToMegiBytes(actual))
+	default:
+		if _, unitBytes, isBytes := byteUnit(resourceType); isBytes {
+			return fmt.Sprintf("%d", formatToUnit(actual, unitBytes))
+		}
+		return fmt.Sprintf("%d", actual.Value())
+	}
+}
+
+// resourceCSVUnit returns the unit resourceCSVString formats a resource in.
+func resourceCSVUnit(resourceType string) string {
+	switch resourceType {
+	case "cpu":
+		return "milli"
+	default:
+		unit, _, _ := byteUnit(resourceType)
+		return unit
 	}
-	return fmt.Sprintf("%d", actual.MilliValue())
 }
 
 func resourceCSVPercentageString(actual, divisor resource.Quantity) string {
@@ -479,31 +734,31 @@ func resourceCSVPercentageString(actual, divisor resource.Quantity) string {
 	return fmt.Sprintf("%d", int64(utilPercent))
 }
 
-func (rm *resourceMetric) capacityString() string {
+func (rm *ResourceMetric) capacityString() string {
 	return resourceCSVString(rm.resourceType, rm.allocatable)
 }
 
-func (rm *resourceMetric) requestActualString() string {
+func (rm *ResourceMetric) requestActualString() string {
 	return resourceCSVString(rm.resourceType, rm.request)
 }
 
-func (rm *resourceMetric) requestPercentageString() string {
+func (rm *ResourceMetric) requestPercentageString() string {
 	return resourceCSVPercentageString(rm.request, rm.allocatable)
 }
 
-func (rm *resourceMetric) limitActualString() string {
+func (rm *ResourceMetric) limitActualString() string {
 	return resourceCSVString(rm.resourceType, rm.limit)
 }
 
-func (rm *resourceMetric) limitPercentageString() string {
+func (rm *ResourceMetric) limitPercentageString() string {
 	return resourceCSVPercentageString(rm.limit, rm.allocatable)
 }
 
-func (rm *resourceMetric) utilActualString() string {
+func (rm *ResourceMetric) utilActualString() string {
 	return resourceCSVString(rm.resourceType, rm.utilization)
 }
 
-func (rm *resourceMetric) utilPercentageString() string {
+func (rm *ResourceMetric) utilPercentageString() string {
 	return resourceCSVPercentageString(rm.utilization, rm.allocatable)
 }
 
@@ -28,25 +28,27 @@ import (
 
 func TestBuildClusterMetricEmpty(t *testing.T) {
 	cm := buildClusterMetric(
-		&corev1.PodList{}, &v1beta1.PodMetricsList{}, &corev1.NodeList{}, &v1beta1.NodeMetricsList{},
+		&corev1.PodList{}, &v1beta1.PodMetricsList{}, &corev1.NodeList{}, &v1beta1.NodeMetricsList{}, defaultResourceNames,
 	)
 
-	expected := clusterMetric{
-		cpu: &resourceMetric{
-			resourceType: "cpu",
-			allocatable:  resource.Quantity{},
-			request:      resource.Quantity{},
-			limit:        resource.Quantity{},
-			utilization:  resource.Quantity{},
-		},
-		memory: &resourceMetric{
-			resourceType: "memory",
-			allocatable:  resource.Quantity{},
-			request:      resource.Quantity{},
-			limit:        resource.Quantity{},
-			utilization:  resource.Quantity{},
+	expected := ClusterMetric{
+		resources: map[corev1.ResourceName]*ResourceMetric{
+			corev1.ResourceCPU: {
+				resourceType: "cpu",
+				allocatable:  resource.Quantity{},
+				request:      resource.Quantity{},
+				limit:        resource.Quantity{},
+				utilization:  resource.Quantity{},
+			},
+			corev1.ResourceMemory: {
+				resourceType: "memory",
+				allocatable:  resource.Quantity{},
+				request:      resource.Quantity{},
+				limit:        resource.Quantity{},
+				utilization:  resource.Quantity{},
+			},
 		},
-		nodeMetrics: map[string]*nodeMetric{},
+		nodeMetrics: map[string]*NodeMetric{},
 		podCount:    &podCount{},
 	}
 
@@ -142,33 +144,33 @@ func TestBuildClusterMetricFull(t *testing.T) {
 					},
 				},
 			},
-		},
+		}, defaultResourceNames,
 	)
 
-	cpuExpected := &resourceMetric{
+	cpuExpected := &ResourceMetric{
 		allocatable: resource.MustParse("1000m"),
 		request:     resource.MustParse("350m"),
 		limit:       resource.MustParse("400m"),
 		utilization: resource.MustParse("43m"),
 	}
 
-	memoryExpected := &resourceMetric{
+	memoryExpected := &ResourceMetric{
 		allocatable: resource.MustParse("4000Mi"),
 		request:     resource.MustParse("400Mi"),
 		limit:       resource.MustParse("700Mi"),
 		utilization: resource.MustParse("349Mi"),
 	}
 
-	assert.NotNil(t, cm.cpu)
-	ensureEqualResourceMetric(t, cm.cpu, cpuExpected)
-	assert.NotNil(t, cm.memory)
-	ensureEqualResourceMetric(t, cm.memory, memoryExpected)
+	assert.NotNil(t, cm.resources[corev1.ResourceCPU])
+	ensureEqualResourceMetric(t, cm.resources[corev1.ResourceCPU], cpuExpected)
+	assert.NotNil(t, cm.resources[corev1.ResourceMemory])
+	ensureEqualResourceMetric(t, cm.resources[corev1.ResourceMemory], memoryExpected)
 
 	assert.NotNil(t, cm.nodeMetrics["example-node-1"])
-	assert.NotNil(t, cm.nodeMetrics["example-node-1"].cpu)
-	ensureEqualResourceMetric(t, cm.nodeMetrics["example-node-1"].cpu, cpuExpected)
-	assert.NotNil(t, cm.nodeMetrics["example-node-1"].memory)
-	ensureEqualResourceMetric(t, cm.nodeMetrics["example-node-1"].memory, memoryExpected)
+	assert.NotNil(t, cm.nodeMetrics["example-node-1"].resources[corev1.ResourceCPU])
+	ensureEqualResourceMetric(t, cm.nodeMetrics["example-node-1"].resources[corev1.ResourceCPU], cpuExpected)
+	assert.NotNil(t, cm.nodeMetrics["example-node-1"].resources[corev1.ResourceMemory])
+	ensureEqualResourceMetric(t, cm.nodeMetrics["example-node-1"].resources[corev1.ResourceMemory], memoryExpected)
 
 	assert.Len(t, cm.nodeMetrics["example-node-1"].podMetrics, 1)
 
@@ -178,10 +180,10 @@ func TestBuildClusterMetricFull(t *testing.T) {
 	memoryExpected.utilization = resource.MustParse("299Mi")
 
 	assert.NotNil(t, pm["default-example-pod"])
-	assert.NotNil(t, pm["default-example-pod"].cpu)
-	ensureEqualResourceMetric(t, pm["default-example-pod"].cpu, cpuExpected)
-	assert.NotNil(t, pm["default-example-pod"].memory)
-	ensureEqualResourceMetric(t, pm["default-example-pod"].memory, memoryExpected)
+	assert.NotNil(t, pm["default-example-pod"].resources[corev1.ResourceCPU])
+	ensureEqualResourceMetric(t, pm["default-example-pod"].resources[corev1.ResourceCPU], cpuExpected)
+	assert.NotNil(t, pm["default-example-pod"].resources[corev1.ResourceMemory])
+	ensureEqualResourceMetric(t, pm["default-example-pod"].resources[corev1.ResourceMemory], memoryExpected)
 }
 
 func TestSortByPodCount(t *testing.T) {
@@ -239,7 +241,7 @@ func TestSortByPodCount(t *testing.T) {
 		},
 	}
 
-	cm := buildClusterMetric(podList, nil, nodeList, nil)
+	cm := buildClusterMetric(podList, nil, nodeList, nil, defaultResourceNames)
 	sortedNodes := cm.getSortedNodeMetrics("pod.count")
 
 	// Node 1 should come first as it has 2 pods vs 1 pod on node 2
@@ -249,7 +251,324 @@ func TestSortByPodCount(t *testing.T) {
 	assert.Equal(t, int64(1), sortedNodes[1].podCount.current)
 }
 
-func ensureEqualResourceMetric(t *testing.T, actual *resourceMetric, expected *resourceMetric) {
+func TestBuildClusterMetricExtendedResources(t *testing.T) {
+	gpu := corev1.ResourceName("nvidia.com/gpu")
+	nodeList := &corev1.NodeList{
+		Items: []corev1.Node{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "gpu-node",
+				},
+				Status: corev1.NodeStatus{
+					Allocatable: corev1.ResourceList{
+						"cpu":  resource.MustParse("4"),
+						gpu:    resource.MustParse("4"),
+						"pods": resource.MustParse("110"),
+					},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cpu-node",
+				},
+				Status: corev1.NodeStatus{
+					Allocatable: corev1.ResourceList{
+						"cpu":  resource.MustParse("4"),
+						"pods": resource.MustParse("110"),
+					},
+				},
+			},
+		},
+	}
+
+	podList := &corev1.PodList{
+		Items: []corev1.Pod{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "trainer",
+					Namespace: "ml",
+				},
+				Spec: corev1.PodSpec{
+					NodeName: "gpu-node",
+					Containers: []corev1.Container{
+						{
+							Name: "trainer",
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									"cpu": resource.MustParse("1"),
+									gpu:   resource.MustParse("3"),
+								},
+								Limits: corev1.ResourceList{
+									gpu: resource.MustParse("3"),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	cm := buildClusterMetric(podList, nil, nodeList, nil, []corev1.ResourceName{corev1.ResourceCPU, gpu})
+
+	assert.Len(t, cm.resources, 2)
+	ensureEqualResourceMetric(t, cm.resources[gpu], &ResourceMetric{
+		allocatable: resource.MustParse("4"),
+		request:     resource.MustParse("3"),
+		limit:       resource.MustParse("3"),
+	})
+	ensureEqualResourceMetric(t, cm.nodeMetrics["cpu-node"].resources[gpu], &ResourceMetric{})
+
+	pm := cm.nodeMetrics["gpu-node"].podMetrics["ml-trainer"]
+	assert.Equal(t, "3 (75%%)", pm.resources[gpu].requestString(false))
+	assert.Equal(t, "1/4", pm.resources[gpu].requestString(true))
+	assert.Equal(t, "3", pm.containerMetrics["trainer"].resources[gpu].requestActualString())
+
+	sortedNodes := cm.getSortedNodeMetrics("nvidia.com/gpu.request")
+	assert.Equal(t, "gpu-node", sortedNodes[0].name)
+	assert.Equal(t, "cpu-node", sortedNodes[1].name)
+}
+
+func TestBuildClusterMetricPending(t *testing.T) {
+	nodeList := &corev1.NodeList{
+		Items: []corev1.Node{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node-1",
+				},
+				Status: corev1.NodeStatus{
+					Allocatable: corev1.ResourceList{
+						"cpu":    resource.MustParse("1"),
+						"memory": resource.MustParse("1Gi"),
+						"pods":   resource.MustParse("110"),
+					},
+				},
+			},
+		},
+	}
+
+	pendingPod := func(name, cpu, reason string) corev1.Pod {
+		p := corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name: "app",
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								"cpu": resource.MustParse(cpu),
+							},
+						},
+					},
+				},
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+			},
+		}
+		if reason != "" {
+			p.Status.Conditions = []corev1.PodCondition{
+				{
+					Type:   corev1.PodScheduled,
+					Status: corev1.ConditionFalse,
+					Reason: reason,
+				},
+			}
+		}
+		return p
+	}
+
+	podList := &corev1.PodList{
+		Items: []corev1.Pod{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "running",
+					Namespace: "default",
+				},
+				Spec: corev1.PodSpec{
+					NodeName: "node-1",
+					Containers: []corev1.Container{
+						{
+							Name: "app",
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									"cpu": resource.MustParse("500m"),
+								},
+							},
+						},
+					},
+				},
+			},
+			pendingPod("pending-1", "2", "Unschedulable"),
+			pendingPod("pending-2", "250m", ""),
+		},
+	}
+
+	cm := buildClusterMetric(podList, nil, nodeList, nil, defaultResourceNames)
+
+	ensureEqualResourceMetric(t, cm.resources[corev1.ResourceCPU], &ResourceMetric{
+		allocatable: resource.MustParse("1"),
+		request:     resource.MustParse("500m"),
+	})
+	assert.Equal(t, int64(1), cm.podCount.current)
+
+	assert.NotNil(t, cm.unscheduled)
+	assert.Equal(t, UnscheduledNodeName, cm.unscheduled.name)
+	assert.Equal(t, int64(2), cm.unscheduled.podCount.current)
+	assert.Len(t, cm.unscheduled.podMetrics, 2)
+	ensureEqualResourceMetric(t, cm.unscheduled.resources[corev1.ResourceCPU], &ResourceMetric{
+		request: resource.MustParse("2250m"),
+	})
+	assert.Equal(t, "Unschedulable", cm.unscheduled.podMetrics["default-pending-1"].pendingReason)
+	assert.Equal(t, "", cm.unscheduled.podMetrics["default-pending-2"].pendingReason)
+}
+
+func TestBuildClusterMetricContainerTypes(t *testing.T) {
+	always := corev1.ContainerRestartPolicyAlways
+	resources := func(cpuRequest, cpuLimit string) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{"cpu": resource.MustParse(cpuRequest)},
+			Limits:   corev1.ResourceList{"cpu": resource.MustParse(cpuLimit)},
+		}
+	}
+
+	podList := &corev1.PodList{Items: []corev1.Pod{{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Overhead: corev1.ResourceList{"cpu": resource.MustParse("250m"), "memory": resource.MustParse("120Mi")},
+			InitContainers: []corev1.Container{
+				{Name: "migrate", Resources: resources("500m", "1")},
+				{Name: "proxy", Resources: resources("100m", "200m"), RestartPolicy: &always},
+			},
+			Containers: []corev1.Container{{Name: "app", Resources: resources("200m", "400m")}},
+			EphemeralContainers: []corev1.EphemeralContainer{{
+				EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debugger"},
+			}},
+		},
+	}}}
+	pmList := &v1beta1.PodMetricsList{Items: []v1beta1.PodMetrics{{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Containers: []v1beta1.ContainerMetrics{
+			{Name: "app", Usage: corev1.ResourceList{"cpu": resource.MustParse("150m")}},
+			{Name: "proxy", Usage: corev1.ResourceList{"cpu": resource.MustParse("20m")}},
+			{Name: "debugger", Usage: corev1.ResourceList{"cpu": resource.MustParse("5m")}},
+		},
+	}}}
+
+	cm := buildClusterMetric(podList, pmList, getGroupTestNodeList(), nil, defaultResourceNames)
+	pm := cm.nodeMetrics["node-1"].podMetrics["default-web"]
+
+	containers := [][]string{}
+	for _, ctm := range pm.getSortedContainerMetrics("name") {
+		rm := ctm.resources[corev1.ResourceCPU]
+		containers = append(containers, []string{ctm.name, ctm.containerType, rm.request.String(), rm.limit.String(), rm.utilization.String()})
+	}
+	assert.Equal(t, [][]string{
+		{"migrate", InitContainerType, "500m", "1", "0"},
+		{"proxy", SidecarContainerType, "100m", "200m", "20m"},
+		{"app", AppContainerType, "200m", "400m", "150m"},
+		{"debugger", EphemeralContainerType, "0", "0", "5m"},
+		{PodOverheadName, OverheadContainerType, "250m", "250m", "0"},
+	}, containers)
+
+	// Overhead is only added to the limits of resources the pod is limited on.
+	overhead := pm.containerMetrics[PodOverheadName].resources[corev1.ResourceMemory]
+	assert.Equal(t, "120Mi", overhead.request.String())
+	assert.True(t, overhead.limit.IsZero())
+
+	// The pod requests the larger of its init container and the containers
+	// that run alongside each other, plus the overhead, and uses the sum of
+	// the utilization of every container.
+	ensureEqualResourceMetric(t, pm.resources[corev1.ResourceCPU], &ResourceMetric{
+		allocatable: resource.MustParse("2"),
+		request:     resource.MustParse("750m"),
+		limit:       resource.MustParse("1250m"),
+		utilization: resource.MustParse("175m"),
+	})
+}
+
+func TestResourceString(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		resourceType string
+		actual       string
+		allocatable  string
+		expected     string
+		available    string
+		csv          string
+		value        string
+	}{
+		{"cpu", "cpu", "250m", "1", "250m (25%%)", "750m/1000m", "250", "250m"},
+		{"memory", "memory", "1Gi", "4Gi", "1024Mi (25%%)", "3072Mi/4096Mi", "1024", "1024Mi"},
+		{"ephemeral storage", "ephemeral-storage", "10Gi", "100Gi", "10Gi (10%%)", "90Gi/100Gi", "10", "10Gi"},
+		{"ephemeral storage rounds up", "ephemeral-storage", "1500Mi", "100Gi", "2Gi (1%%)", "98Gi/100Gi", "2", "2Gi"},
+		{"2Mi hugepages", "hugepages-2Mi", "512Mi", "1Gi", "512Mi (50%%)", "512Mi/1024Mi", "512", "512Mi"},
+		{"1Gi hugepages", "hugepages-1Gi", "2Gi", "8Gi", "2Gi (25%%)", "6Gi/8Gi", "2", "2Gi"},
+		{"64Ki hugepages", "hugepages-64Ki", "128Ki", "512Ki", "128Ki (25%%)", "384Ki/512Ki", "128", "128Ki"},
+		{"extended resource", "nvidia.com/gpu", "1", "4", "1 (25%%)", "3/4", "1", "1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := resource.MustParse(tc.actual)
+			allocatable := resource.MustParse(tc.allocatable)
+			rm := ResourceMetric{resourceType: tc.resourceType, allocatable: allocatable}
+
+			assert.Equal(t, tc.expected, resourceString(tc.resourceType, actual, allocatable, false))
+			assert.Equal(t, tc.available, resourceString(tc.resourceType, actual, allocatable, true))
+			assert.Equal(t, tc.csv, resourceCSVString(tc.resourceType, actual))
+			assert.Equal(t, tc.value, rm.valueFunction()(actual))
+		})
+	}
+}
+
+func TestResourceDisplayName(t *testing.T) {
+	assert.Equal(t, "CPU", resourceDisplayName(corev1.ResourceCPU))
+	assert.Equal(t, "MEMORY", resourceDisplayName(corev1.ResourceMemory))
+	assert.Equal(t, "EPHEMERAL-STORAGE", resourceDisplayName(corev1.ResourceEphemeralStorage))
+	assert.Equal(t, "HUGEPAGES-2Mi", resourceDisplayName("hugepages-2Mi"))
+	assert.Equal(t, "Gi", resourceCSVUnit("ephemeral-storage"))
+	assert.Equal(t, "Mi", resourceCSVUnit("hugepages-2Mi"))
+}
+
+func TestParseResourceSortAttribute(t *testing.T) {
+	var testCases = []struct {
+		sortBy     string
+		name       corev1.ResourceName
+		field      string
+		percentage bool
+		ok         bool
+	}{
+		{"cpu.util", corev1.ResourceCPU, "util", false, true},
+		{"mem.request.percentage", corev1.ResourceMemory, "request", true, true},
+		{"storage.request.percentage", corev1.ResourceEphemeralStorage, "request", true, true},
+		{"hugepages-2Mi.limit", "hugepages-2Mi", "limit", false, true},
+		{"nvidia.com/gpu.limit", "nvidia.com/gpu", "limit", false, true},
+		{"nvidia.com/gpu.request.percentage", "nvidia.com/gpu", "request", true, true},
+		{"cpu.util.p95", corev1.ResourceCPU, "util.p95", false, true},
+		{"mem.util.max.percentage", corev1.ResourceMemory, "util.max", true, true},
+		{"nvidia.com/gpu.util.avg", "nvidia.com/gpu", "util.avg", false, true},
+		{"cpu.request.p95", "", "", false, false},
+		{"pod.count", "", "", false, false},
+		{"name", "", "", false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.sortBy, func(t *testing.T) {
+			name, field, percentage, ok := parseResourceSortAttribute(tc.sortBy)
+			assert.Equal(t, tc.name, name)
+			assert.Equal(t, tc.field, field)
+			assert.Equal(t, tc.percentage, percentage)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+func ensureEqualResourceMetric(t *testing.T, actual *ResourceMetric, expected *ResourceMetric) {
 	assert.Equal(t, actual.allocatable.MilliValue(), expected.allocatable.MilliValue())
 	assert.Equal(t, actual.utilization.MilliValue(), expected.utilization.MilliValue())
 	assert.Equal(t, actual.request.MilliValue(), expected.request.MilliValue())
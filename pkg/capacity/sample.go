@@ -0,0 +1,155 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"math"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+// sampleStats are the statistics reported for sampled utilization, in the
+// order their columns are output.
+var sampleStats = []string{
+	StatAvg,
+	StatMax,
+	StatP95,
+}
+
+// metricsSample holds the utilization of pods and nodes fetched at one
+// point in time.
+type metricsSample struct {
+	pmList *v1beta1.PodMetricsList
+	nmList *v1beta1.NodeMetricsList
+}
+
+// sampled returns true if utilization should be reported as statistics over
+// several samples rather than as a single value.
+func (opts Options) sampled() bool {
+	return opts.ShowUtil && opts.Samples > 1
+}
+
+// setSamples records the utilization of each resource in samples, which
+// hold the same nodes and pods as cm, as the utilization samples of the
+// matching resource in cm. Resources missing from a sample are recorded as
+// unused.
+func (cm *ClusterMetric) setSamples(samples []ClusterMetric) {
+	for _, s := range samples {
+		appendSamples(cm.resources, s.resources)
+		for name, nm := range cm.nodeMetrics {
+			nm.appendSamples(s.nodeMetrics[name])
+		}
+		if cm.unscheduled != nil {
+			cm.unscheduled.appendSamples(s.unscheduled)
+		}
+	}
+}
+
+func (nm *NodeMetric) appendSamples(s *NodeMetric) {
+	if s == nil {
+		s = &NodeMetric{}
+	}
+	appendSamples(nm.resources, s.resources)
+	for key, pm := range nm.podMetrics {
+		pm.appendSamples(s.podMetrics[key])
+	}
+}
+
+func (pm *PodMetric) appendSamples(s *PodMetric) {
+	if s == nil {
+		s = &PodMetric{}
+	}
+	appendSamples(pm.resources, s.resources)
+	for name, ctm := range pm.containerMetrics {
+		var resources map[corev1.ResourceName]*ResourceMetric
+		if sctm := s.containerMetrics[name]; sctm != nil {
+			resources = sctm.resources
+		}
+		appendSamples(ctm.resources, resources)
+	}
+}
+
+// appendSamples appends the utilization of each resource in s to the
+// samples of the matching resource in resources.
+func appendSamples(resources, s map[corev1.ResourceName]*ResourceMetric) {
+	for name, rm := range resources {
+		var q resource.Quantity
+		if srm := s[name]; srm != nil {
+			q = srm.utilization.DeepCopy()
+		}
+		rm.samples = append(rm.samples, q)
+	}
+}
+
+// addSamples adds each of the given utilization samples to the sample of
+// rm taken at the same time.
+func (rm *ResourceMetric) addSamples(samples []resource.Quantity) {
+	for i, q := range samples {
+		if i < len(rm.samples) {
+			rm.samples[i].Add(q)
+		} else {
+			rm.samples = append(rm.samples, q.DeepCopy())
+		}
+	}
+}
+
+// utilizationStat returns the average, maximum or 95th percentile of the
+// utilization samples of rm, or its utilization if it has not been sampled.
+func (rm *ResourceMetric) utilizationStat(stat string) resource.Quantity {
+	if len(rm.samples) == 0 {
+		return rm.utilization
+	}
+
+	values := make([]int64, len(rm.samples))
+	for i, q := range rm.samples {
+		values[i] = q.MilliValue()
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	var value int64
+	switch stat {
+	case StatMax:
+		value = values[len(values)-1]
+	case StatP95:
+		// The nearest rank, so the result is always one of the samples.
+		rank := int(math.Ceil(0.95 * float64(len(values))))
+		value = values[rank-1]
+	default:
+		var sum int64
+		for _, v := range values {
+			sum += v
+		}
+		value = sum / int64(len(values))
+	}
+
+	format := resource.DecimalSI
+	if _, _, isBytes := byteUnit(rm.resourceType); isBytes {
+		format = resource.BinarySI
+	}
+	return *resource.NewMilliQuantity(value, format)
+}
+
+// utilStatStrings returns the string representation of each statistic of
+// the utilization samples of rm, keyed by statistic.
+func (rm *ResourceMetric) utilStatStrings(availableFormat bool) map[string]string {
+	strs := map[string]string{}
+	for _, stat := range sampleStats {
+		strs[stat] = resourceString(rm.resourceType, rm.utilizationStat(stat), rm.allocatable, availableFormat)
+	}
+	return strs
+}
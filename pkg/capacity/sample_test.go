@@ -0,0 +1,165 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	v1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+func TestUtilizationStat(t *testing.T) {
+	rm := &ResourceMetric{resourceType: "cpu", utilization: resource.MustParse("50m")}
+	assert.Equal(t, "50m", quantityString(rm.utilizationStat(StatP95)))
+
+	for i := 1; i <= 20; i++ {
+		rm.samples = append(rm.samples, *resource.NewMilliQuantity(int64(i*10), resource.DecimalSI))
+	}
+	assert.Equal(t, "105m", quantityString(rm.utilizationStat(StatAvg)))
+	assert.Equal(t, "200m", quantityString(rm.utilizationStat(StatMax)))
+	assert.Equal(t, "190m", quantityString(rm.utilizationStat(StatP95)))
+
+	rm = &ResourceMetric{resourceType: "memory", samples: []resource.Quantity{resource.MustParse("1Gi")}}
+	assert.Equal(t, "1Gi", quantityString(rm.utilizationStat(StatP95)))
+}
+
+func TestCollectSamples(t *testing.T) {
+	objects := []runtime.Object{}
+	for _, node := range getGroupTestNodeList().Items {
+		objects = append(objects, node.DeepCopy())
+	}
+	for _, pod := range getGroupTestPodList().Items {
+		objects = append(objects, pod.DeepCopy())
+	}
+
+	webCPU := []string{"100m", "300m", "200m"}
+	nodeCPU := []string{"1", "1500m", "500m"}
+	podCalls, nodeCalls := 0, 0
+	mClientset := metricsfake.NewSimpleClientset()
+	mClientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		usage := webCPU[podCalls]
+		podCalls++
+		return true, &v1beta1.PodMetricsList{Items: []v1beta1.PodMetrics{
+			samplePodMetrics("default", "web-1", usage),
+			samplePodMetrics("default", "web-2", "100m"),
+		}}, nil
+	})
+	mClientset.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		usage := nodeCPU[nodeCalls]
+		nodeCalls++
+		return true, &v1beta1.NodeMetricsList{Items: []v1beta1.NodeMetrics{
+			{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Usage: corev1.ResourceList{"cpu": resource.MustParse(usage)}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}, Usage: corev1.ResourceList{"cpu": resource.MustParse("500m")}},
+		}}, nil
+	})
+
+	cm, err := Collect(context.TODO(), fake.NewSimpleClientset(objects...), mClientset, Options{
+		ShowUtil:       true,
+		Samples:        3,
+		SampleInterval: time.Millisecond,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, podCalls)
+	assert.Equal(t, 3, nodeCalls)
+
+	cpu := cm.Resource(corev1.ResourceCPU)
+	assert.Equal(t, "1500m", quantityString(cpu.UtilizationStat(StatAvg)))
+	assert.Equal(t, "2", quantityString(cpu.UtilizationStat(StatMax)))
+
+	node := cm.nodeMetrics["node-1"]
+	assert.Equal(t, "500m", quantityString(node.resources["cpu"].utilization))
+	assert.Equal(t, "1", quantityString(node.resources["cpu"].utilizationStat(StatAvg)))
+	assert.Equal(t, "1500m", quantityString(node.resources["cpu"].utilizationStat(StatP95)))
+
+	web := node.podMetrics["default-web-1"]
+	assert.Equal(t, "200m", quantityString(web.resources["cpu"].utilization))
+	assert.Equal(t, "200m", quantityString(web.resources["cpu"].utilizationStat(StatAvg)))
+	assert.Equal(t, "300m", quantityString(web.resources["cpu"].utilizationStat(StatMax)))
+	assert.Equal(t, "300m", quantityString(web.containerMetrics["app"].resources["cpu"].utilizationStat(StatMax)))
+
+	pods := node.getSortedPodMetrics("cpu.util.max")
+	assert.Equal(t, "web-1", pods[0].name)
+	assert.Equal(t, "dns", pods[1].name)
+
+	// Grouped samples are summed before statistics are taken, so the peak
+	// of a group is the peak of its combined utilization.
+	grouped := cm.groupBy(NamespaceGroup)
+	assert.Equal(t, "400m", quantityString(grouped.nodeMetrics["default"].resources["cpu"].utilizationStat(StatMax)))
+	assert.Equal(t, "0", quantityString(grouped.nodeMetrics["kube-system"].resources["cpu"].utilizationStat(StatMax)))
+}
+
+func TestCollectSamplesCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mClientset := metricsfake.NewSimpleClientset()
+	mClientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		cancel()
+		return true, &v1beta1.PodMetricsList{}, nil
+	})
+
+	_, err := Collect(ctx, fake.NewSimpleClientset(), mClientset, Options{
+		ShowUtil:       true,
+		Samples:        3,
+		SampleInterval: time.Hour,
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSampledLineItems(t *testing.T) {
+	tp := &tablePrinter{
+		opts: Options{
+			ShowUtil:   true,
+			Samples:    3,
+			HideLimits: true,
+			Resources:  []string{"cpu"},
+		},
+	}
+	assert.Equal(t, []string{"NODE", "CPU REQUESTS", "CPU UTIL AVG", "CPU UTIL MAX", "CPU UTIL P95"}, tp.getLineItems(tp.headerLine()))
+
+	cp := &csvPrinter{opts: tp.opts}
+	assert.Equal(t, []string{`"NODE"`, "CPU CAPACITY (milli)", "CPU REQUESTS", "CPU REQUESTS %%",
+		"CPU UTIL AVG", "CPU UTIL AVG %%", "CPU UTIL MAX", "CPU UTIL MAX %%", "CPU UTIL P95", "CPU UTIL P95 %%"}, cp.getLineItems(cp.headerLine()))
+
+	rm := &ResourceMetric{
+		resourceType: "cpu",
+		allocatable:  resource.MustParse("1"),
+		samples:      []resource.Quantity{resource.MustParse("100m"), resource.MustParse("300m")},
+	}
+	lp := &listPrinter{opts: tp.opts}
+	out := lp.buildListResourceOutput(rm)
+	assert.Equal(t, "", out.Utilization)
+	assert.Equal(t, "200m", out.UtilizationAvg)
+	assert.Equal(t, "300m", out.UtilizationMax)
+	assert.Equal(t, "30%", out.UtilizationMaxPct)
+}
+
+func samplePodMetrics(namespace, name, cpu string) v1beta1.PodMetrics {
+	return v1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Containers: []v1beta1.ContainerMetrics{{
+			Name:  "app",
+			Usage: corev1.ResourceList{"cpu": resource.MustParse(cpu)},
+		}},
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+	metrics "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// metricsPrefix is prepended to the name of every exported metric.
+const metricsPrefix = "kube_capacity"
+
+// staleRefreshes is the number of refresh intervals that may pass without
+// a successful refresh before /healthz reports the exporter as unhealthy.
+const staleRefreshes = 3
+
+// readHeaderTimeout limits how long a client may take to send the headers
+// of a request, so slow clients cannot hold connections open.
+const readHeaderTimeout = 10 * time.Second
+
+// exporter serves the most recently collected cluster resource data in the
+// Prometheus text exposition format.
+type exporter struct {
+	clientset  kubernetes.Interface
+	mClientset metrics.Interface
+	opts       Options
+
+	mu          sync.RWMutex
+	metrics     []byte
+	lastRefresh time.Time
+}
+
+type gauge struct {
+	name    string
+	help    string
+	samples []gaugeSample
+}
+
+type gaugeSample struct {
+	labels [][2]string
+	value  int64
+}
+
+// Serve runs an HTTP server exposing cluster resource data as Prometheus
+// metrics on /metrics, refreshed every RefreshInterval, and a health check
+// on /healthz. Metrics that can't be refreshed are left as they were,
+// and reported as stale by the health check. The server is shut down when
+// ctx is cancelled.
+func Serve(ctx context.Context, opts Options) error {
+	clientset, mClientset, err := newClientSets(opts)
+	if err != nil {
+		return err
+	}
+	e := &exporter{
+		clientset:  clientset,
+		mClientset: mClientset,
+		opts:       opts,
+	}
+
+	if err := e.refresh(ctx); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	mux.HandleFunc("/healthz", e.healthz)
+	server := &http.Server{Addr: opts.ListenAddress, Handler: mux, ReadHeaderTimeout: readHeaderTimeout}
+
+	go func() {
+		ticker := time.NewTicker(opts.RefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				_ = server.Shutdown(context.Background())
+				return
+			case <-ticker.C:
+				if err := e.refresh(ctx); err != nil && ctx.Err() == nil {
+					fmt.Fprintf(os.Stderr, "Error refreshing metrics: %v\n", err)
+				}
+			}
+		}
+	}()
+
+	fmt.Printf("Serving metrics on %s\n", opts.ListenAddress)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serving metrics: %w", err)
+	}
+	return nil
+}
+
+// refresh collects cluster resource data and renders it as metrics.
+func (e *exporter) refresh(ctx context.Context) error {
+	cm, err := collectClusterMetric(ctx, e.clientset, e.mClientset, e.opts, false)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	writeGauges(&buf, buildGauges(&cm, e.opts))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.metrics = buf.Bytes()
+	e.lastRefresh = time.Now()
+	return nil
+}
+
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The lock is released before writing so a slow scraper can't hold up
+	// refreshes. Refreshes replace the slice rather than modifying it.
+	e.mu.RLock()
+	metrics := e.metrics
+	e.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write(metrics)
+}
+
+// healthz reports whether metrics have been refreshed recently.
+func (e *exporter) healthz(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.lastRefresh.IsZero() || time.Since(e.lastRefresh) > staleRefreshes*e.opts.RefreshInterval {
+		http.Error(w, "metrics have not been refreshed recently", http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = fmt.Fprintln(w, "ok")
+}
+
+// buildGauges returns gauges for the requests, limits, allocatable and, if
+// enabled, utilization of each resource for the cluster and each node, and
+// for each pod and container if they have been requested. CPU and memory
+// have dedicated gauges; other resources share gauges with a resource label.
+func buildGauges(cm *ClusterMetric, opts Options) []*gauge {
+	gauges := []*gauge{}
+	gaugesByName := map[string]*gauge{}
+	add := func(name, help string, labels [][2]string, value int64) {
+		g := gaugesByName[name]
+		if g == nil {
+			g = &gauge{name: name, help: help}
+			gaugesByName[name] = g
+			gauges = append(gauges, g)
+		}
+		g.samples = append(g.samples, gaugeSample{labels: labels, value: value})
+	}
+
+	addResources := func(scope string, resources map[corev1.ResourceName]*ResourceMetric, labels [][2]string, allocatable bool) {
+		fields := []string{"requests", "limits"}
+		if allocatable {
+			fields = append([]string{"allocatable"}, fields...)
+		}
+		if opts.ShowUtil {
+			fields = append(fields, "utilization")
+		}
+
+		for _, resourceName := range opts.resourceNames() {
+			rm := resources[resourceName]
+			if rm == nil {
+				continue
+			}
+			resourceLabels := labels
+			if resourceName != corev1.ResourceCPU && resourceName != corev1.ResourceMemory {
+				resourceLabels = append(append([][2]string{}, labels...), [2]string{"resource", string(resourceName)})
+			}
+			for _, field := range fields {
+				name, help, value := gaugeValue(scope, resourceName, field, gaugeField(rm, field))
+				add(name, help, resourceLabels, value)
+			}
+		}
+	}
+
+	addPodCount := func(scope string, pc *podCount, labels [][2]string) {
+		add(fmt.Sprintf("%s_%s_pods", metricsPrefix, scope),
+			fmt.Sprintf("Number of pods on the %s.", scope), labels, pc.current)
+		add(fmt.Sprintf("%s_%s_pods_allocatable", metricsPrefix, scope),
+			fmt.Sprintf("Number of pods that can be scheduled to the %s.", scope), labels, pc.allocatable)
+	}
+
+	addResources("cluster", cm.resources, nil, true)
+	addPodCount("cluster", cm.podCount, nil)
+
+	nodeMetrics := cm.getSortedNodeMetrics("name")
+	for _, nm := range nodeMetrics {
+		labels := [][2]string{{"node", nm.name}}
+		addResources("node", nm.resources, labels, true)
+		addPodCount("node", nm.podCount, labels)
+	}
+
+	if cm.unscheduled != nil {
+		nodeMetrics = append(nodeMetrics, cm.unscheduled)
+	}
+
+	if opts.ShowPods || opts.ShowContainers {
+		for _, nm := range nodeMetrics {
+			for _, pm := range nm.getSortedPodMetrics("name") {
+				labels := [][2]string{{"namespace", pm.namespace}, {"pod", pm.name}, {"node", nm.name}}
+				addResources("pod", pm.resources, labels, false)
+			}
+		}
+	}
+
+	if opts.ShowContainers {
+		for _, nm := range nodeMetrics {
+			for _, pm := range nm.getSortedPodMetrics("name") {
+				for _, ctm := range pm.getSortedContainerMetrics("name") {
+					if ctm.containerType == OverheadContainerType {
+						continue
+					}
+					labels := [][2]string{{"namespace", pm.namespace}, {"pod", pm.name}, {"container", ctm.name}, {"node", nm.name}}
+					addResources("container", ctm.resources, labels, false)
+				}
+			}
+		}
+	}
+
+	return gauges
+}
+
+func gaugeField(rm *ResourceMetric, field string) resource.Quantity {
+	switch field {
+	case "allocatable":
+		return rm.allocatable
+	case "limits":
+		return rm.limit
+	case "utilization":
+		return rm.utilization
+	default:
+		return rm.request
+	}
+}
+
+// gaugeValue returns the name and help text of the gauge a resource field
+// is reported in, along with its value in the unit of that gauge.
+func gaugeValue(scope string, resourceName corev1.ResourceName, field string, q resource.Quantity) (string, string, int64) {
+	switch resourceName {
+	case corev1.ResourceCPU:
+		return fmt.Sprintf("%s_%s_cpu_%s_millicores", metricsPrefix, scope, field),
+			fmt.Sprintf("CPU %s of the %s in millicores.", field, scope), q.MilliValue()
+	case corev1.ResourceMemory:
+		return fmt.Sprintf("%s_%s_memory_%s_bytes", metricsPrefix, scope, field),
+			fmt.Sprintf("Memory %s of the %s in bytes.", field, scope), q.Value()
+	default:
+		return fmt.Sprintf("%s_%s_resource_%s", metricsPrefix, scope, field),
+			fmt.Sprintf("Resource %s of the %s, in bytes for storage and hugepages.", field, scope), q.Value()
+	}
+}
+
+// writeGauges writes gauges in the Prometheus text exposition format.
+func writeGauges(w io.Writer, gauges []*gauge) {
+	for _, g := range gauges {
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n", g.name, g.help)
+		_, _ = fmt.Fprintf(w, "# TYPE %s gauge\n", g.name)
+		for _, s := range g.samples {
+			if len(s.labels) == 0 {
+				_, _ = fmt.Fprintf(w, "%s %d\n", g.name, s.value)
+				continue
+			}
+
+			labels := make([]string, 0, len(s.labels))
+			for _, label := range s.labels {
+				labels = append(labels, fmt.Sprintf("%s=\"%s\"", label[0], escapeLabelValue(label[1])))
+			}
+			_, _ = fmt.Fprintf(w, "%s{%s} %d\n", g.name, strings.Join(labels, ","), s.value)
+		}
+	}
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
@@ -0,0 +1,123 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	v1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+func TestExporter(t *testing.T) {
+	objects := []runtime.Object{}
+	for _, node := range getGroupTestNodeList().Items {
+		objects = append(objects, node.DeepCopy())
+	}
+	for _, pod := range getGroupTestPodList().Items {
+		objects = append(objects, pod.DeepCopy())
+	}
+
+	// The metrics API serves pod metrics as "pods", which the fake clientset
+	// cannot guess from the PodMetrics kind.
+	mClientset := metricsfake.NewSimpleClientset()
+	err := mClientset.Tracker().Create(v1beta1.SchemeGroupVersion.WithResource("pods"), &v1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Containers: []v1beta1.ContainerMetrics{
+			{
+				Name: "app",
+				Usage: corev1.ResourceList{
+					"cpu":    resource.MustParse("50m"),
+					"memory": resource.MustParse("100Mi"),
+				},
+			},
+		},
+	}, "default")
+	assert.NoError(t, err)
+
+	e := &exporter{
+		clientset:  fake.NewSimpleClientset(objects...),
+		mClientset: mClientset,
+		opts: Options{
+			ShowUtil:        true,
+			ShowPods:        true,
+			Namespace:       "default",
+			RefreshInterval: time.Minute,
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	e.healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	assert.NoError(t, e.refresh(context.TODO()))
+
+	rec = httptest.NewRecorder()
+	e.healthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "# HELP kube_capacity_node_cpu_requests_millicores CPU requests of the node in millicores.\n"+
+		"# TYPE kube_capacity_node_cpu_requests_millicores gauge\n"+
+		"kube_capacity_node_cpu_requests_millicores{node=\"node-1\"} 200\n"+
+		"kube_capacity_node_cpu_requests_millicores{node=\"node-2\"} 400\n")
+	assert.Contains(t, body, "kube_capacity_cluster_cpu_allocatable_millicores 4000\n")
+	assert.Contains(t, body, "kube_capacity_cluster_memory_limits_bytes 1073741824\n")
+	assert.Contains(t, body, "kube_capacity_node_pods{node=\"node-1\"} 1\n")
+	assert.Contains(t, body, "kube_capacity_pod_cpu_utilization_millicores{namespace=\"default\",pod=\"web-1\",node=\"node-1\"} 50\n")
+	assert.NotContains(t, body, "kube_capacity_container_")
+}
+
+func TestBuildGaugesExtendedResources(t *testing.T) {
+	nodeList := getGroupTestNodeList()
+	nodeList.Items[0].Status.Allocatable = corev1.ResourceList{
+		"cpu":            resource.MustParse("2"),
+		"nvidia.com/gpu": resource.MustParse("4"),
+		"pods":           resource.MustParse("110"),
+	}
+
+	cm := buildClusterMetric(&corev1.PodList{}, nil, nodeList, nil, []corev1.ResourceName{"cpu", "nvidia.com/gpu"})
+	gauges := buildGauges(&cm, Options{Resources: []string{"cpu", "nvidia.com/gpu"}})
+
+	var gpu *gauge
+	for _, g := range gauges {
+		if g.name == "kube_capacity_node_resource_allocatable" {
+			gpu = g
+		}
+	}
+	assert.NotNil(t, gpu)
+	assert.Equal(t, gaugeSample{
+		labels: [][2]string{{"node", "node-1"}, {"resource", "nvidia.com/gpu"}},
+		value:  4,
+	}, gpu.samples[0])
+
+	assert.Equal(t, `a\"b\\c\n`, escapeLabelValue("a\"b\\c\n"))
+}
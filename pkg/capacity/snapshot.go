@@ -0,0 +1,269 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// snapshotVersion is the version of the snapshot file format.
+const snapshotVersion = 1
+
+// snapshot is the serialized form of a ClusterMetric. Quantities are stored
+// as they were collected rather than formatted for output.
+type snapshot struct {
+	Version     int                                       `json:"version"`
+	Time        time.Time                                 `json:"time"`
+	Resources   map[corev1.ResourceName]*snapshotResource `json:"resources"`
+	PodCount    *snapshotPodCount                         `json:"podCount"`
+	Nodes       []*snapshotNode                           `json:"nodes"`
+	Unscheduled *snapshotNode                             `json:"unscheduled,omitempty"`
+}
+
+type snapshotResource struct {
+	Allocatable resource.Quantity `json:"allocatable"`
+	Utilization resource.Quantity `json:"utilization"`
+	Request     resource.Quantity `json:"request"`
+	Limit       resource.Quantity `json:"limit"`
+}
+
+type snapshotPodCount struct {
+	Current     int64 `json:"current"`
+	Allocatable int64 `json:"allocatable"`
+}
+
+type snapshotNode struct {
+	Name      string                                    `json:"name"`
+	Labels    map[string]string                         `json:"labels,omitempty"`
+	Resources map[corev1.ResourceName]*snapshotResource `json:"resources"`
+	PodCount  *snapshotPodCount                         `json:"podCount"`
+	Pods      []*snapshotPod                            `json:"pods,omitempty"`
+}
+
+type snapshotPod struct {
+	Name          string                                    `json:"name"`
+	Namespace     string                                    `json:"namespace"`
+	PendingReason string                                    `json:"pendingReason,omitempty"`
+	WorkloadKind  string                                    `json:"workloadKind,omitempty"`
+	WorkloadName  string                                    `json:"workloadName,omitempty"`
+	QOSClass      string                                    `json:"qosClass,omitempty"`
+	PriorityClass string                                    `json:"priorityClass,omitempty"`
+	Resources     map[corev1.ResourceName]*snapshotResource `json:"resources"`
+	Containers    []*snapshotContainer                      `json:"containers,omitempty"`
+}
+
+type snapshotContainer struct {
+	Name      string                                    `json:"name"`
+	Type      string                                    `json:"type,omitempty"`
+	Resources map[corev1.ResourceName]*snapshotResource `json:"resources"`
+}
+
+// SaveSnapshot gathers cluster resource data and writes it to a file that
+// can later be compared with DiffSnapshots
+func SaveSnapshot(ctx context.Context, opts Options, fileName string) error {
+	cm, err := fetchClusterMetric(ctx, opts, true)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(newSnapshot(&cm, time.Now()), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling snapshot: %w", err)
+	}
+
+	if err := os.WriteFile(fileName, raw, 0o644); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	return nil
+}
+
+// loadSnapshot reads a snapshot written by SaveSnapshot, which may also have
+// been converted to YAML.
+func loadSnapshot(fileName string) (*snapshot, error) {
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &snapshot{}
+	if err := yaml.Unmarshal(raw, s); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", fileName, err)
+	}
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf("%s has unsupported snapshot version %d", fileName, s.Version)
+	}
+	return s, nil
+}
+
+func newSnapshot(cm *ClusterMetric, now time.Time) *snapshot {
+	s := &snapshot{
+		Version:   snapshotVersion,
+		Time:      now.UTC(),
+		Resources: newSnapshotResources(cm.resources),
+		PodCount:  newSnapshotPodCount(cm.podCount),
+		Nodes:     []*snapshotNode{},
+	}
+
+	for _, nm := range cm.getSortedNodeMetrics("name") {
+		s.Nodes = append(s.Nodes, newSnapshotNode(nm))
+	}
+	if cm.unscheduled != nil {
+		s.Unscheduled = newSnapshotNode(cm.unscheduled)
+	}
+
+	return s
+}
+
+func newSnapshotNode(nm *NodeMetric) *snapshotNode {
+	sn := &snapshotNode{
+		Name:      nm.name,
+		Labels:    nm.labels,
+		Resources: newSnapshotResources(nm.resources),
+		PodCount:  newSnapshotPodCount(nm.podCount),
+	}
+
+	for _, pm := range nm.getSortedPodMetrics("name") {
+		sp := &snapshotPod{
+			Name:          pm.name,
+			Namespace:     pm.namespace,
+			PendingReason: pm.pendingReason,
+			WorkloadKind:  pm.workload.kind,
+			WorkloadName:  pm.workload.name,
+			QOSClass:      pm.qosClass,
+			PriorityClass: pm.priorityClass,
+			Resources:     newSnapshotResources(pm.resources),
+		}
+		for _, ctm := range pm.getSortedContainerMetrics("name") {
+			sp.Containers = append(sp.Containers, &snapshotContainer{
+				Name:      ctm.name,
+				Type:      ctm.containerType,
+				Resources: newSnapshotResources(ctm.resources),
+			})
+		}
+		sn.Pods = append(sn.Pods, sp)
+	}
+
+	return sn
+}
+
+func newSnapshotResources(resources map[corev1.ResourceName]*ResourceMetric) map[corev1.ResourceName]*snapshotResource {
+	sr := map[corev1.ResourceName]*snapshotResource{}
+	for name, rm := range resources {
+		sr[name] = &snapshotResource{
+			Allocatable: rm.allocatable,
+			Utilization: rm.utilization,
+			Request:     rm.request,
+			Limit:       rm.limit,
+		}
+	}
+	return sr
+}
+
+func newSnapshotPodCount(pc *podCount) *snapshotPodCount {
+	if pc == nil {
+		return &snapshotPodCount{}
+	}
+	return &snapshotPodCount{Current: pc.current, Allocatable: pc.allocatable}
+}
+
+// clusterMetric rebuilds the ClusterMetric a snapshot was taken of.
+func (s *snapshot) clusterMetric() *ClusterMetric {
+	cm := &ClusterMetric{
+		resources:   s.resourceMetrics(s.Resources),
+		nodeMetrics: map[string]*NodeMetric{},
+		podCount:    s.podCount(s.PodCount),
+	}
+
+	for _, sn := range s.Nodes {
+		cm.nodeMetrics[sn.Name] = s.nodeMetric(sn)
+	}
+	if s.Unscheduled != nil {
+		cm.unscheduled = s.nodeMetric(s.Unscheduled)
+	}
+
+	return cm
+}
+
+func (s *snapshot) nodeMetric(sn *snapshotNode) *NodeMetric {
+	nm := &NodeMetric{
+		name:       sn.Name,
+		labels:     sn.Labels,
+		resources:  s.resourceMetrics(sn.Resources),
+		podMetrics: map[string]*PodMetric{},
+		podCount:   s.podCount(sn.PodCount),
+	}
+
+	for _, sp := range sn.Pods {
+		pm := &PodMetric{
+			name:             sp.Name,
+			namespace:        sp.Namespace,
+			pendingReason:    sp.PendingReason,
+			workload:         workloadRef{kind: sp.WorkloadKind, name: sp.WorkloadName},
+			qosClass:         sp.QOSClass,
+			priorityClass:    sp.PriorityClass,
+			resources:        s.resourceMetrics(sp.Resources),
+			containerMetrics: map[string]*ContainerMetric{},
+		}
+		for _, sc := range sp.Containers {
+			// Snapshots taken before container types were recorded only
+			// hold regular containers.
+			containerType := sc.Type
+			if containerType == "" {
+				containerType = AppContainerType
+			}
+			pm.containerMetrics[sc.Name] = &ContainerMetric{
+				name:          sc.Name,
+				containerType: containerType,
+				resources:     s.resourceMetrics(sc.Resources),
+			}
+		}
+		nm.podMetrics[fmt.Sprintf("%s-%s", sp.Namespace, sp.Name)] = pm
+	}
+
+	return nm
+}
+
+// resourceMetrics converts snapshot resources back to ResourceMetrics,
+// including every resource in the snapshot so lookups by name always
+// succeed.
+func (s *snapshot) resourceMetrics(sr map[corev1.ResourceName]*snapshotResource) map[corev1.ResourceName]*ResourceMetric {
+	resources := map[corev1.ResourceName]*ResourceMetric{}
+	for name := range s.Resources {
+		rm := &ResourceMetric{resourceType: string(name)}
+		if r := sr[name]; r != nil {
+			rm.allocatable = r.Allocatable
+			rm.utilization = r.Utilization
+			rm.request = r.Request
+			rm.limit = r.Limit
+		}
+		resources[name] = rm
+	}
+	return resources
+}
+
+func (s *snapshot) podCount(spc *snapshotPodCount) *podCount {
+	if spc == nil {
+		return &podCount{}
+	}
+	return &podCount{current: spc.Current, allocatable: spc.Allocatable}
+}
@@ -0,0 +1,64 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	cm := getRecommendTestClusterMetric()
+	cm.unscheduled = &NodeMetric{
+		name:       UnscheduledNodeName,
+		resources:  newResourceMetrics(defaultResourceNames, nil),
+		podMetrics: map[string]*PodMetric{},
+		podCount:   &podCount{},
+	}
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	raw, err := json.Marshal(newSnapshot(&cm, now))
+	assert.NoError(t, err)
+
+	fileName := filepath.Join(t.TempDir(), "snapshot.json")
+	assert.NoError(t, os.WriteFile(fileName, raw, 0o644))
+
+	s, err := loadSnapshot(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, now, s.Time)
+
+	loaded := s.clusterMetric()
+	opts := Options{ShowUtil: true, ShowPods: true, ShowContainers: true, ShowPodCount: true, ShowPending: true}
+	expected := listPrinter{cm: &cm, opts: opts}
+	actual := listPrinter{cm: loaded, opts: opts}
+	assert.Equal(t, expected.buildListClusterMetrics(), actual.buildListClusterMetrics())
+
+	web1 := loaded.nodeMetrics["node-1"].podMetrics["default-web-1"]
+	assert.Equal(t, workloadRef{kind: "Deployment", name: "web"}, web1.workload)
+	assert.Equal(t, int64(20), web1.containerMetrics["app"].resources["cpu"].utilization.MilliValue())
+}
+
+func TestLoadSnapshotUnsupportedVersion(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "snapshot.yaml")
+	assert.NoError(t, os.WriteFile(fileName, []byte("version: 2\n"), 0o644))
+
+	_, err := loadSnapshot(fileName)
+	assert.ErrorContains(t, err, "unsupported snapshot version 2")
+}
@@ -0,0 +1,40 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+const (
+	//MetricsServerSource is the constant value for reading utilization from the Metrics API
+	MetricsServerSource string = "metrics-server"
+	//KubeletSource is the constant value for reading utilization from the kubelet Summary API of each node
+	KubeletSource string = "kubelet"
+	//PrometheusSource is the constant value for reading utilization over a window from Prometheus
+	PrometheusSource string = "prometheus"
+)
+
+// SupportedMetricsSources returns a string list of sources of utilization
+// supported by this package
+func SupportedMetricsSources() []string {
+	return []string{
+		MetricsServerSource,
+		KubeletSource,
+		PrometheusSource,
+	}
+}
+
+// usesMetricsServer returns true if utilization has been requested from the
+// Metrics API.
+func (opts Options) usesMetricsServer() bool {
+	return opts.ShowUtil && (opts.MetricsSource == "" || opts.MetricsSource == MetricsServerSource)
+}
@@ -15,16 +15,35 @@
 package capacity
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
 type tablePrinter struct {
-	cm   *clusterMetric
+	cm   *ClusterMetric
 	w    *tabwriter.Writer
 	opts Options
+
+	// clusters holds the clusters to print when gathering data from
+	// several contexts, in which case cm holds their fleet totals.
+	clusters []*contextMetric
+	// cluster is the name of the cluster the lines being printed belong to.
+	cluster string
+
+	// out is where the table is written, defaulting to os.Stdout.
+	out io.Writer
+	// previous holds the cells of a previously printed table. Cells that
+	// have changed since then are highlighted.
+	previous map[string]string
+	// cells holds the cells of the printed table, keyed by row and column.
+	cells map[string]string
+	lines [][]string
 }
 
 func (tp *tablePrinter) hasVisibleColumns() bool {
@@ -33,80 +52,184 @@ func (tp *tablePrinter) hasVisibleColumns() bool {
 }
 
 type tableLine struct {
-	node           string
-	namespace      string
-	pod            string
-	container      string
-	cpuRequests    string
-	cpuLimits      string
-	cpuUtil        string
-	memoryRequests string
-	memoryLimits   string
-	memoryUtil     string
-	podCount       string
-}
-
-var headerStrings = tableLine{
-	node:           "NODE",
-	namespace:      "NAMESPACE",
-	pod:            "POD",
-	container:      "CONTAINER",
-	cpuRequests:    "CPU REQUESTS",
-	cpuLimits:      "CPU LIMITS",
-	cpuUtil:        "CPU UTIL",
-	memoryRequests: "MEMORY REQUESTS",
-	memoryLimits:   "MEMORY LIMITS",
-	memoryUtil:     "MEMORY UTIL",
-	podCount:       "POD COUNT",
+	cluster   string
+	node      string
+	namespace string
+	pod       string
+	container string
+	// containerType is the type of a container, e.g. "init".
+	containerType string
+	// qosClass and priorityClass are the classes of a pod, or of a group
+	// of pods when grouping by either.
+	qosClass      string
+	priorityClass string
+	resources     map[corev1.ResourceName]*tableResourceLine
+	// replicas is the number of pods of a workload when grouping by
+	// workload.
+	replicas string
+	podCount string
+	reason   string
+}
+
+type tableResourceLine struct {
+	requests string
+	limits   string
+	util     string
+	// utilStats holds sampled utilization, keyed by statistic.
+	utilStats map[string]string
+}
+
+func (tp *tablePrinter) headerLine() *tableLine {
+	tl := &tableLine{
+		cluster:       "CLUSTER",
+		node:          groupHeader(tp.opts),
+		namespace:     "NAMESPACE",
+		pod:           "POD",
+		container:     "CONTAINER",
+		containerType: "TYPE",
+		qosClass:      "QOS CLASS",
+		priorityClass: "PRIORITY CLASS",
+		resources:     map[corev1.ResourceName]*tableResourceLine{},
+		replicas:      "REPLICAS",
+		podCount:      "POD COUNT",
+		reason:        "REASON",
+	}
+
+	for _, name := range tp.opts.resourceNames() {
+		displayName := resourceDisplayName(name)
+		tl.resources[name] = &tableResourceLine{
+			requests:  displayName + " REQUESTS",
+			limits:    displayName + " LIMITS",
+			util:      displayName + " UTIL",
+			utilStats: map[string]string{},
+		}
+		for _, stat := range sampleStats {
+			tl.resources[name].utilStats[stat] = fmt.Sprintf("%s UTIL %s", displayName, strings.ToUpper(stat))
+		}
+	}
+
+	return tl
 }
 
 func (tp *tablePrinter) Print() {
-	tp.w.Init(os.Stdout, 0, 8, 2, ' ', 0)
-	sortedNodeMetrics := tp.cm.getSortedNodeMetrics(tp.opts.SortBy)
+	out := tp.out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	var buf bytes.Buffer
+	tp.w.Init(&buf, 0, 8, 2, ' ', 0)
+	tp.lines = nil
+
+	tp.printLine(tp.headerLine())
+
+	if tp.clusters != nil {
+		tp.printContexts()
+	} else {
+		tp.printCluster(tp.cm, false)
+	}
+
+	err := tp.w.Flush()
+	if err != nil {
+		fmt.Printf("Error writing to table: %s", err)
+	}
+
+	tp.cells = tp.cellValues()
+	table := buf.String()
+	if tp.previous != nil {
+		table = tp.highlightChanges(table)
+	}
+	_, _ = fmt.Fprint(out, table)
+}
 
-	tp.printLine(&headerStrings)
+// printCluster prints the cluster line, if requested or if there is more
+// than one node, followed by each node.
+func (tp *tablePrinter) printCluster(cm *ClusterMetric, clusterLine bool) {
+	sortedNodeMetrics := cm.getSortedNodeMetrics(tp.opts.SortBy)
 
-	if len(sortedNodeMetrics) > 1 {
-		tp.printClusterLine()
+	if clusterLine || len(sortedNodeMetrics) > 1 {
+		tp.printClusterLine(cm)
+		for _, class := range getSortedClassMetrics(cm.classes, tp.opts.SortBy) {
+			tp.printClassLine(VoidValue, class)
+		}
 	}
 
 	for _, nm := range sortedNodeMetrics {
-		if tp.opts.ShowPods || tp.opts.ShowContainers {
-			tp.printLine(&tableLine{})
+		tp.printNode(nm)
+	}
+
+	if cm.unscheduled != nil {
+		tp.printNode(cm.unscheduled)
+	}
+}
+
+// printContexts prints the fleet totals followed by each cluster that was
+// reached.
+func (tp *tablePrinter) printContexts() {
+	tp.cluster = VoidValue
+	tp.printClusterLine(tp.cm)
+
+	for _, c := range tp.clusters {
+		if c.err != nil {
+			continue
 		}
+		tp.cluster = c.name
+		tp.printCluster(c.cm, true)
+	}
+}
+
+func (tp *tablePrinter) printNode(nm *NodeMetric) {
+	if tp.opts.ShowPods || tp.opts.ShowContainers {
+		tp.printLine(&tableLine{})
+	}
+
+	tp.printNodeLine(nm.name, nm)
 
-		tp.printNodeLine(nm.name, nm)
+	if nm.classes == nil {
+		tp.printPods(nm.name, nm)
+		return
+	}
+	for _, class := range getSortedClassMetrics(nm.classes, tp.opts.SortBy) {
+		tp.printClassLine(nm.name, class)
+		tp.printPods(nm.name, class)
+	}
+}
 
-		if tp.opts.ShowPods || tp.opts.ShowContainers {
-			podMetrics := nm.getSortedPodMetrics(tp.opts.SortBy)
-			for _, pm := range podMetrics {
-				tp.printPodLine(nm.name, pm)
-				if tp.opts.ShowContainers {
-					containerMetrics := pm.getSortedContainerMetrics(tp.opts.SortBy)
-					for _, containerMetric := range containerMetrics {
-						tp.printContainerLine(nm.name, pm, containerMetric)
-					}
+// printPods prints the pods of a node or class, and their containers, if
+// requested.
+func (tp *tablePrinter) printPods(nodeName string, nm *NodeMetric) {
+	if tp.opts.ShowPods || tp.opts.ShowContainers {
+		podMetrics := nm.getSortedPodMetrics(tp.opts.SortBy)
+		for _, pm := range podMetrics {
+			tp.printPodLine(nodeName, pm)
+			if tp.opts.ShowContainers {
+				containerMetrics := pm.getSortedContainerMetrics(tp.opts.SortBy)
+				for _, containerMetric := range containerMetrics {
+					tp.printContainerLine(nodeName, pm, containerMetric)
 				}
 			}
 		}
 	}
-
-	err := tp.w.Flush()
-	if err != nil {
-		fmt.Printf("Error writing to table: %s", err)
-	}
 }
 
 func (tp *tablePrinter) printLine(tl *tableLine) {
+	if tl.cluster == "" && tl.node != "" {
+		tl.cluster = tp.cluster
+	}
 	lineItems := tp.getLineItems(tl)
+	tp.lines = append(tp.lines, lineItems)
 	_, _ = fmt.Fprintln(tp.w, strings.Join(lineItems[:], "\t "))
 }
 
 func (tp *tablePrinter) getLineItems(tl *tableLine) []string {
-	lineItems := []string{tl.node}
+	lineItems := []string{}
+	if tp.clusters != nil {
+		lineItems = append(lineItems, tl.cluster)
+	}
+	lineItems = append(lineItems, tl.node)
 
 	if tp.opts.ShowContainers || tp.opts.ShowPods {
-		if tp.opts.Namespace == "" {
+		if tp.opts.Namespace == "" && tp.opts.GroupBy != NamespaceGroup {
 			lineItems = append(lineItems, tl.namespace)
 		}
 		lineItems = append(lineItems, tl.pod)
@@ -114,95 +237,236 @@ func (tp *tablePrinter) getLineItems(tl *tableLine) []string {
 
 	if tp.opts.ShowContainers {
 		lineItems = append(lineItems, tl.container)
+		lineItems = append(lineItems, tl.containerType)
 	}
 
-	if !tp.opts.HideRequests {
-		lineItems = append(lineItems, tl.cpuRequests)
+	if tp.opts.showQOSClass() {
+		lineItems = append(lineItems, tl.qosClass)
 	}
-	if !tp.opts.HideLimits {
-		lineItems = append(lineItems, tl.cpuLimits)
+	if tp.opts.showPriorityClass() {
+		lineItems = append(lineItems, tl.priorityClass)
 	}
 
-	if tp.opts.ShowUtil {
-		lineItems = append(lineItems, tl.cpuUtil)
+	if tp.opts.GroupBy == WorkloadGroup {
+		lineItems = append(lineItems, tl.replicas)
 	}
 
-	if !tp.opts.HideRequests {
-		lineItems = append(lineItems, tl.memoryRequests)
-	}
-	if !tp.opts.HideLimits {
-		lineItems = append(lineItems, tl.memoryLimits)
-	}
+	for _, name := range tp.opts.resourceNames() {
+		rl := tl.resources[name]
+		if rl == nil {
+			rl = &tableResourceLine{}
+		}
+
+		if !tp.opts.HideRequests {
+			lineItems = append(lineItems, rl.requests)
+		}
+		if !tp.opts.HideLimits {
+			lineItems = append(lineItems, rl.limits)
+		}
 
-	if tp.opts.ShowUtil {
-		lineItems = append(lineItems, tl.memoryUtil)
+		if tp.opts.sampled() {
+			for _, stat := range sampleStats {
+				lineItems = append(lineItems, rl.utilStats[stat])
+			}
+		} else if tp.opts.ShowUtil {
+			lineItems = append(lineItems, rl.util)
+		}
 	}
 
 	if tp.opts.ShowPodCount {
 		lineItems = append(lineItems, tl.podCount)
 	}
 
+	if tp.opts.ShowPending && (tp.opts.ShowPods || tp.opts.ShowContainers) {
+		lineItems = append(lineItems, tl.reason)
+	}
+
 	return lineItems
 }
 
-func (tp *tablePrinter) printClusterLine() {
+func (tp *tablePrinter) resourceLines(resources map[corev1.ResourceName]*ResourceMetric) map[corev1.ResourceName]*tableResourceLine {
+	lines := map[corev1.ResourceName]*tableResourceLine{}
+	for name, rm := range resources {
+		lines[name] = &tableResourceLine{
+			requests: rm.requestString(tp.opts.AvailableFormat),
+			limits:   rm.limitString(tp.opts.AvailableFormat),
+			util:     rm.utilString(tp.opts.AvailableFormat),
+		}
+		if tp.opts.sampled() {
+			lines[name].utilStats = rm.utilStatStrings(tp.opts.AvailableFormat)
+		}
+	}
+	return lines
+}
+
+func (tp *tablePrinter) printClusterLine(cm *ClusterMetric) {
 	tp.printLine(&tableLine{
-		node:           VoidValue,
-		namespace:      VoidValue,
-		pod:            VoidValue,
-		container:      VoidValue,
-		cpuRequests:    tp.cm.cpu.requestString(tp.opts.AvailableFormat),
-		cpuLimits:      tp.cm.cpu.limitString(tp.opts.AvailableFormat),
-		cpuUtil:        tp.cm.cpu.utilString(tp.opts.AvailableFormat),
-		memoryRequests: tp.cm.memory.requestString(tp.opts.AvailableFormat),
-		memoryLimits:   tp.cm.memory.limitString(tp.opts.AvailableFormat),
-		memoryUtil:     tp.cm.memory.utilString(tp.opts.AvailableFormat),
-		podCount:       tp.cm.podCount.podCountString(),
+		node:          VoidValue,
+		namespace:     VoidValue,
+		pod:           VoidValue,
+		container:     VoidValue,
+		containerType: VoidValue,
+		qosClass:      VoidValue,
+		priorityClass: VoidValue,
+		resources:     tp.resourceLines(cm.resources),
+		replicas:      VoidValue,
+		podCount:      cm.podCount.podCountString(),
+		reason:        VoidValue,
 	})
 }
 
-func (tp *tablePrinter) printNodeLine(nodeName string, nm *nodeMetric) {
+func (tp *tablePrinter) printNodeLine(nodeName string, nm *NodeMetric) {
 	tp.printLine(&tableLine{
-		node:           nodeName,
-		namespace:      VoidValue,
-		pod:            VoidValue,
-		container:      VoidValue,
-		cpuRequests:    nm.cpu.requestString(tp.opts.AvailableFormat),
-		cpuLimits:      nm.cpu.limitString(tp.opts.AvailableFormat),
-		cpuUtil:        nm.cpu.utilString(tp.opts.AvailableFormat),
-		memoryRequests: nm.memory.requestString(tp.opts.AvailableFormat),
-		memoryLimits:   nm.memory.limitString(tp.opts.AvailableFormat),
-		memoryUtil:     nm.memory.utilString(tp.opts.AvailableFormat),
-		podCount:       nm.podCount.podCountString(),
+		node:          nodeName,
+		namespace:     VoidValue,
+		pod:           VoidValue,
+		container:     VoidValue,
+		containerType: VoidValue,
+		qosClass:      VoidValue,
+		priorityClass: VoidValue,
+		resources:     tp.resourceLines(nm.resources),
+		replicas:      nm.podCount.podCountCurrentString(),
+		podCount:      nm.podCount.podCountString(),
+		reason:        VoidValue,
 	})
 }
 
-func (tp *tablePrinter) printPodLine(nodeName string, pm *podMetric) {
+// printClassLine prints the totals of a QoS or priority class of a node, or
+// of the cluster if nodeName is VoidValue.
+func (tp *tablePrinter) printClassLine(nodeName string, class *NodeMetric) {
+	tl := &tableLine{
+		node:          nodeName,
+		namespace:     VoidValue,
+		pod:           VoidValue,
+		container:     VoidValue,
+		containerType: VoidValue,
+		qosClass:      VoidValue,
+		priorityClass: VoidValue,
+		resources:     tp.resourceLines(class.resources),
+		podCount:      class.podCount.podCountString(),
+		reason:        VoidValue,
+	}
+	if tp.opts.GroupBy == PriorityGroup {
+		tl.priorityClass = class.name
+	} else {
+		tl.qosClass = class.name
+	}
+	tp.printLine(tl)
+}
+
+func (tp *tablePrinter) printPodLine(nodeName string, pm *PodMetric) {
 	tp.printLine(&tableLine{
-		node:           nodeName,
-		namespace:      pm.namespace,
-		pod:            pm.name,
-		container:      VoidValue,
-		cpuRequests:    pm.cpu.requestString(tp.opts.AvailableFormat),
-		cpuLimits:      pm.cpu.limitString(tp.opts.AvailableFormat),
-		cpuUtil:        pm.cpu.utilString(tp.opts.AvailableFormat),
-		memoryRequests: pm.memory.requestString(tp.opts.AvailableFormat),
-		memoryLimits:   pm.memory.limitString(tp.opts.AvailableFormat),
-		memoryUtil:     pm.memory.utilString(tp.opts.AvailableFormat),
+		node:          nodeName,
+		namespace:     pm.namespace,
+		pod:           pm.name,
+		container:     VoidValue,
+		containerType: VoidValue,
+		qosClass:      noneIfEmpty(pm.qosClass),
+		priorityClass: noneIfEmpty(pm.priorityClass),
+		resources:     tp.resourceLines(pm.resources),
+		reason:        pm.pendingReason,
 	})
 }
 
-func (tp *tablePrinter) printContainerLine(nodeName string, pm *podMetric, cm *containerMetric) {
+func (tp *tablePrinter) printContainerLine(nodeName string, pm *PodMetric, cm *ContainerMetric) {
 	tp.printLine(&tableLine{
-		node:           nodeName,
-		namespace:      pm.namespace,
-		pod:            pm.name,
-		container:      cm.name,
-		cpuRequests:    cm.cpu.requestString(tp.opts.AvailableFormat),
-		cpuLimits:      cm.cpu.limitString(tp.opts.AvailableFormat),
-		cpuUtil:        cm.cpu.utilString(tp.opts.AvailableFormat),
-		memoryRequests: cm.memory.requestString(tp.opts.AvailableFormat),
-		memoryLimits:   cm.memory.limitString(tp.opts.AvailableFormat),
-		memoryUtil:     cm.memory.utilString(tp.opts.AvailableFormat),
+		node:          nodeName,
+		namespace:     pm.namespace,
+		pod:           pm.name,
+		container:     cm.name,
+		containerType: cm.containerType,
+		qosClass:      noneIfEmpty(pm.qosClass),
+		priorityClass: noneIfEmpty(pm.priorityClass),
+		resources:     tp.resourceLines(cm.resources),
+		reason:        pm.pendingReason,
 	})
 }
+
+// identityColumns returns the number of leading columns that identify a row
+// rather than hold values.
+func (tp *tablePrinter) identityColumns() int {
+	columns := 1
+	if tp.clusters != nil {
+		columns++
+	}
+	if tp.opts.ShowContainers || tp.opts.ShowPods {
+		if tp.opts.Namespace == "" && tp.opts.GroupBy != NamespaceGroup {
+			columns++
+		}
+		columns++
+	}
+	if tp.opts.ShowContainers {
+		columns += 2
+	}
+	if tp.opts.showQOSClass() {
+		columns++
+	}
+	if tp.opts.showPriorityClass() {
+		columns++
+	}
+	return columns
+}
+
+// cellKey returns the key of a value cell in cellValues, or false if the
+// cell does not hold a value.
+func (tp *tablePrinter) cellKey(line []string, column int) (string, bool) {
+	identity := tp.identityColumns()
+	if column < identity || column >= len(line) || len(tp.lines) == 0 || line[column] == "" {
+		return "", false
+	}
+	return strings.Join(line[:identity], "\x00") + "\x00" + tp.lines[0][column], true
+}
+
+// cellValues returns the value cells of the printed table.
+func (tp *tablePrinter) cellValues() map[string]string {
+	cells := map[string]string{}
+	for i, line := range tp.lines {
+		if i == 0 {
+			continue
+		}
+		for column, value := range line {
+			if key, ok := tp.cellKey(line, column); ok {
+				cells[key] = value
+			}
+		}
+	}
+	return cells
+}
+
+// highlightChanges wraps the cells of a rendered table that differ from
+// their previous values in terminal escape codes. Highlighting is applied
+// after alignment so the escape codes do not affect column widths.
+func (tp *tablePrinter) highlightChanges(table string) string {
+	rendered := strings.SplitAfter(table, "\n")
+	for i, line := range tp.lines {
+		if i == 0 || i >= len(rendered) {
+			continue
+		}
+
+		var sb strings.Builder
+		offset := 0
+		for column, value := range line {
+			if value == "" {
+				continue
+			}
+			start := strings.Index(rendered[i][offset:], value)
+			if start < 0 {
+				break
+			}
+			start += offset
+			end := start + len(value)
+
+			sb.WriteString(rendered[i][offset:start])
+			key, ok := tp.cellKey(line, column)
+			if previous, found := tp.previous[key]; ok && found && previous != value {
+				sb.WriteString(HighlightStart + value + HighlightEnd)
+			} else {
+				sb.WriteString(value)
+			}
+			offset = end
+		}
+		sb.WriteString(rendered[i][offset:])
+		rendered[i] = sb.String()
+	}
+	return strings.Join(rendered, "")
+}
@@ -15,9 +15,14 @@
 package capacity
 
 import (
+	"bytes"
+	"strings"
 	"testing"
+	"text/tabwriter"
 
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func TestGetLineItems(t *testing.T) {
@@ -51,18 +56,37 @@ func TestGetLineItems(t *testing.T) {
 		},
 	}
 
+	tpGPU := &tablePrinter{
+		opts: Options{
+			Namespace: "example",
+			Resources: []string{"cpu", "nvidia.com/gpu"},
+		},
+	}
+
 	tl := &tableLine{
-		node:           "example-node-1",
-		namespace:      "example-namespace",
-		pod:            "nginx-fsde",
-		container:      "nginx",
-		cpuRequests:    "100m",
-		cpuLimits:      "200m",
-		cpuUtil:        "14m",
-		memoryRequests: "1000Mi",
-		memoryLimits:   "2000Mi",
-		memoryUtil:     "326Mi",
-		podCount:       "1/110",
+		node:          "example-node-1",
+		namespace:     "example-namespace",
+		pod:           "nginx-fsde",
+		container:     "nginx",
+		containerType: AppContainerType,
+		resources: map[corev1.ResourceName]*tableResourceLine{
+			corev1.ResourceCPU: {
+				requests: "100m",
+				limits:   "200m",
+				util:     "14m",
+			},
+			corev1.ResourceMemory: {
+				requests: "1000Mi",
+				limits:   "2000Mi",
+				util:     "326Mi",
+			},
+			"nvidia.com/gpu": {
+				requests: "2",
+				limits:   "2",
+				util:     "0",
+			},
+		},
+		podCount: "1/110",
 	}
 
 	var testCases = []struct {
@@ -91,6 +115,7 @@ func TestGetLineItems(t *testing.T) {
 				"example-namespace",
 				"nginx-fsde",
 				"nginx",
+				AppContainerType,
 				"100m",
 				"200m",
 				"1000Mi",
@@ -105,6 +130,7 @@ func TestGetLineItems(t *testing.T) {
 				"example-namespace",
 				"nginx-fsde",
 				"nginx",
+				AppContainerType,
 				"100m",
 				"200m",
 				"14m",
@@ -113,6 +139,17 @@ func TestGetLineItems(t *testing.T) {
 				"326Mi",
 				"1/110",
 			},
+		}, {
+			name: "extended resources",
+			tp:   tpGPU,
+			tl:   tl,
+			expected: []string{
+				"example-node-1",
+				"100m",
+				"200m",
+				"2",
+				"2",
+			},
 		},
 	}
 
@@ -123,3 +160,67 @@ func TestGetLineItems(t *testing.T) {
 		})
 	}
 }
+
+func TestGetLineItemsClasses(t *testing.T) {
+	tl := &tableLine{
+		node:          "example-node-1",
+		namespace:     "example-namespace",
+		pod:           "nginx-fsde",
+		qosClass:      "Burstable",
+		priorityClass: NoneGroupName,
+		resources: map[corev1.ResourceName]*tableResourceLine{
+			corev1.ResourceCPU:    {requests: "100m", limits: "200m"},
+			corev1.ResourceMemory: {requests: "1000Mi", limits: "2000Mi"},
+		},
+	}
+
+	tp := &tablePrinter{opts: Options{ShowPods: true, ShowClasses: true}}
+	assert.Equal(t, []string{
+		"example-node-1", "example-namespace", "nginx-fsde", "Burstable", NoneGroupName,
+		"100m", "200m", "1000Mi", "2000Mi",
+	}, tp.getLineItems(tl))
+	assert.Equal(t, 5, tp.identityColumns())
+
+	// Classes are only shown with pods unless output is grouped by them.
+	tp = &tablePrinter{opts: Options{ShowClasses: true}}
+	assert.Equal(t, []string{"example-node-1", "100m", "200m", "1000Mi", "2000Mi"}, tp.getLineItems(tl))
+
+	tp = &tablePrinter{opts: Options{GroupBy: PriorityGroup}}
+	assert.Equal(t, []string{"example-node-1", NoneGroupName, "100m", "200m", "1000Mi", "2000Mi"}, tp.getLineItems(tl))
+}
+
+func TestPrintHighlightsChanges(t *testing.T) {
+	opts := Options{ShowPods: true}
+	printTable := func(podList *corev1.PodList, previous map[string]string) (string, map[string]string) {
+		cm := buildClusterMetric(podList, nil, getGroupTestNodeList(), nil, defaultResourceNames)
+		var buf bytes.Buffer
+		tp := &tablePrinter{
+			cm:       &cm,
+			w:        new(tabwriter.Writer),
+			opts:     opts,
+			out:      &buf,
+			previous: previous,
+		}
+		tp.Print()
+		return buf.String(), tp.cells
+	}
+
+	first, cells := printTable(getGroupTestPodList(), nil)
+	assert.NotContains(t, first, HighlightStart)
+	assert.Equal(t, "200m (10%%)", cells["node-1\x00default\x00web-1\x00CPU REQUESTS"])
+
+	unchanged, _ := printTable(getGroupTestPodList(), cells)
+	assert.Equal(t, first, unchanged)
+
+	podList := getGroupTestPodList()
+	podList.Items[0].Spec.Containers[0].Resources.Requests["cpu"] = resource.MustParse("300m")
+	changed, _ := printTable(podList, cells)
+	assert.Contains(t, changed, HighlightStart+"300m (15%%)"+HighlightEnd)
+	assert.Contains(t, changed, HighlightStart+"800m (20%%)"+HighlightEnd)
+	assert.NotContains(t, changed, HighlightStart+"256Mi")
+
+	// Highlighting must not change the alignment of the table.
+	stripped := strings.NewReplacer(HighlightStart, "", HighlightEnd, "").Replace(changed)
+	plain, _ := printTable(podList, nil)
+	assert.Equal(t, plain, stripped)
+}
@@ -0,0 +1,77 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	metrics "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// watchAndPrint refreshes cluster resource data every WatchInterval using
+// the same clients, redrawing the table in place and highlighting the cells
+// that changed since the previous refresh. A failed refresh is reported
+// above the last table and retried on the next tick, so only a failure of
+// the first refresh is returned. Watching stops without an error when ctx
+// is cancelled.
+func watchAndPrint(ctx context.Context, clientset kubernetes.Interface, mClientset metrics.Interface, opts Options) error {
+	if !(&tablePrinter{opts: opts}).hasVisibleColumns() {
+		return ErrNoVisibleColumns
+	}
+
+	var previous map[string]string
+	var last string
+	for {
+		cm, err := collectClusterMetric(ctx, clientset, mClientset, opts, opts.GroupBy == WorkloadGroup)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil && last == "" {
+			return err
+		}
+
+		if err == nil {
+			var buf bytes.Buffer
+			tp := &tablePrinter{
+				cm:       cm.group(opts),
+				w:        new(tabwriter.Writer),
+				opts:     opts,
+				out:      &buf,
+				previous: previous,
+			}
+			tp.Print()
+			previous = tp.cells
+			last = buf.String()
+		}
+
+		fmt.Print(ClearScreen)
+		fmt.Printf("Every %s: kube-capacity\t%s\n\n", opts.WatchInterval, time.Now().Format(time.RFC1123))
+		if err != nil {
+			fmt.Printf("Error refreshing, showing the last successful refresh: %v\n\n", err)
+		}
+		fmt.Print(last)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.WatchInterval):
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/robscott/kube-capacity/pkg/capacity"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "List containers that are missing CPU or memory requests or limits",
+	Long: "Lists the containers, including init and ephemeral containers, that are missing CPU or memory requests or limits, " +
+		"grouped by namespace and owning workload. With --util, the utilization of containers without requests is summarized. " +
+		"Supports table, json and yaml output.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := validateAuditOutputType(opts.OutputFormat); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		ctx := cmd.Context()
+		exitOnError(ctx, capacity.FetchAndAudit(ctx, opts))
+	},
+}
+
+func validateAuditOutputType(outputType string) error {
+	for _, format := range capacity.SupportedAuditOutputs() {
+		if format == outputType {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unsupported Output Type. We only support: %v", capacity.SupportedAuditOutputs())
+}
@@ -0,0 +1,73 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/robscott/kube-capacity/pkg/capacity"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	checkCmd.Flags().StringArrayVarP(&opts.FailIf,
+		"fail-if", "", []string{},
+		fmt.Sprintf("threshold to check of the form <scope>:<attribute><operator><value>, e.g. node:cpu.request.percentage>85, may be repeated (scopes: %v)", capacity.SupportedCheckScopes()))
+	checkCmd.Flags().StringVarP(&opts.Policy,
+		"policy", "", "", "policy file of rules to check, each with a name, scope, failIf condition and optionally namespaces")
+	rootCmd.AddCommand(checkCmd)
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Exit with a non-zero status when resources exceed thresholds",
+	Long: "Checks the requests, limits, utilization and pod counts of the cluster, nodes, namespaces, pods or containers against thresholds " +
+		"and the rules of a policy file, printing the rows that exceed them and exiting with status 8 if any do, for gating CI pipelines and cron jobs. " +
+		"Supports table, json, yaml and junit output.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(opts.FailIf) == 0 && opts.Policy == "" {
+			fmt.Println("at least one --fail-if threshold or a --policy file is required")
+			os.Exit(1)
+		}
+
+		if err := validateCheckOutputType(opts.OutputFormat); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if err := validateGroupBy(opts.GroupBy); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if opts.GroupNodesBy != "" && opts.GroupBy != capacity.NodeGroup {
+			fmt.Println("--group-nodes-by can only be used when grouping by node")
+			os.Exit(1)
+		}
+
+		ctx := cmd.Context()
+		exitOnError(ctx, capacity.FetchAndCheck(ctx, opts))
+	},
+}
+
+func validateCheckOutputType(outputType string) error {
+	for _, format := range capacity.SupportedCheckOutputs() {
+		if format == outputType {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unsupported Output Type. We only support: %v", capacity.SupportedCheckOutputs())
+}
@@ -0,0 +1,44 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/robscott/kube-capacity/pkg/capacity"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <old> <new>",
+	Short: "Compare resource requests, limits, and utilization between two snapshots",
+	Long: "Compares two files written by snapshot save, reporting the change in resource requests, limits, utilization, " +
+		"and pod count of the cluster and each node and namespace that changed. " +
+		"Pods are included with --pods and utilization with --util.",
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := validateOutputType(opts.OutputFormat); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		exitOnError(cmd.Context(), capacity.DiffSnapshots(opts, args[0], args[1]))
+	},
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/robscott/kube-capacity/pkg/capacity"
+)
+
+// metricsServerHint is reported along with errors from the Metrics API.
+const metricsServerHint = "For this to work, metrics-server needs to be running in your cluster"
+
+// prometheusHint is reported along with errors from Prometheus.
+const prometheusHint = "For this to work, Prometheus needs to be scraping cAdvisor metrics from each kubelet"
+
+// kubeletHint is reported along with errors from the kubelet Summary API.
+const kubeletHint = "For this to work, you need permission to get nodes/proxy, and the API server needs to be able to reach the kubelet"
+
+// interruptedExitCode is the exit code used when interrupted, matching the
+// convention for processes terminated by SIGINT.
+const interruptedExitCode = 130
+
+// exitOnError reports an error returned by the capacity package and exits
+// with the code corresponding to it, if it is not nil. Errors caused by ctx
+// being cancelled are reported as an interruption.
+func exitOnError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+
+	if ctx.Err() != nil {
+		exitInterrupted(err)
+	}
+
+	var ce *capacity.ContextError
+	if errors.As(err, &ce) {
+		for _, err := range unwrapJoined(err) {
+			fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		}
+		os.Exit(1)
+	}
+
+	if errors.Is(err, capacity.ErrNoVisibleColumns) {
+		fmt.Fprintln(os.Stderr, "Error: No data columns selected for display. At least one of the following must be enabled:")
+		fmt.Fprintln(os.Stderr, "- Resource requests (enabled by default, disabled with --hide-requests)")
+		fmt.Fprintln(os.Stderr, "- Resource limits (enabled by default, disabled with --hide-limits)")
+		fmt.Fprintln(os.Stderr, "- Resource utilization (enabled with --util)")
+		fmt.Fprintln(os.Stderr, "- Pod count (enabled with --pod-count)")
+		os.Exit(1)
+	}
+
+	// Exceeded thresholds have already been reported along with the rows
+	// that exceeded them, so they are not reported as an error, and are
+	// summarized on stderr to leave JSON and JUnit output intact.
+	var te *capacity.ThresholdError
+	if errors.As(err, &te) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode(err))
+	}
+
+	fmt.Printf("Error %v\n", err)
+	var me *capacity.MetricsError
+	var se *capacity.SummaryError
+	var pe *capacity.PrometheusError
+	if errors.As(err, &me) {
+		fmt.Println(metricsServerHint)
+	} else if errors.As(err, &se) {
+		fmt.Println(kubeletHint)
+	} else if errors.As(err, &pe) {
+		fmt.Println(prometheusHint)
+	}
+	os.Exit(exitCode(err))
+}
+
+// exitInterrupted reports that gathering data was interrupted, along with
+// the contexts that are missing from partial results.
+func exitInterrupted(err error) {
+	missing := []string{}
+	for _, err := range unwrapJoined(err) {
+		var ce *capacity.ContextError
+		if errors.As(err, &ce) {
+			missing = append(missing, ce.Context)
+		}
+	}
+
+	if len(missing) > 0 {
+		fmt.Fprintf(os.Stderr, "Interrupted, results are partial and do not include contexts: %s\n", strings.Join(missing, ", "))
+	} else {
+		fmt.Fprintln(os.Stderr, "Interrupted before results were gathered")
+	}
+	os.Exit(interruptedExitCode)
+}
+
+// exitCode returns the exit code an error is reported with.
+func exitCode(err error) int {
+	var connErr *capacity.ConnectionError
+	var listErr *capacity.ListError
+	var optErr *capacity.OptionError
+	var metricsErr *capacity.MetricsError
+	var summaryErr *capacity.SummaryError
+	var promErr *capacity.PrometheusError
+	var thresholdErr *capacity.ThresholdError
+
+	switch {
+	case errors.As(err, &connErr):
+		if connErr.API == capacity.KubernetesAPI {
+			return 1
+		}
+		return 4
+	case errors.As(err, &listErr):
+		if listErr.Resource == "Nodes" {
+			return 2
+		}
+		return 3
+	case errors.As(err, &optErr):
+		return 3
+	case errors.As(err, &metricsErr):
+		if metricsErr.Resource == "Node" {
+			return 7
+		}
+		return 6
+	case errors.As(err, &summaryErr):
+		return 7
+	case errors.As(err, &promErr):
+		return 6
+	case errors.As(err, &thresholdErr):
+		return 8
+	}
+	return 1
+}
+
+// unwrapJoined returns the errors joined in err, or err itself if it is not
+// a joined error.
+func unwrapJoined(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,65 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/robscott/kube-capacity/pkg/capacity"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	recommendCmd.Flags().Float64VarP(&opts.RequestHeadroom,
+		"request-headroom", "", 1.2, "ratio of utilization to recommend as the request")
+	recommendCmd.Flags().Float64VarP(&opts.LimitHeadroom,
+		"limit-headroom", "", 1.5, "ratio of utilization to recommend as the limit, for containers with limits")
+	recommendCmd.Flags().Float64VarP(&opts.Tolerance,
+		"tolerance", "", 0.25, "fraction a request or limit may differ from the recommendation before it is flagged")
+	recommendCmd.Flags().BoolVarP(&opts.ShowAllRecommendations,
+		"all", "", false, "includes containers that are neither over- nor under-provisioned in output")
+	rootCmd.AddCommand(recommendCmd)
+}
+
+var recommendCmd = &cobra.Command{
+	Use:   "recommend",
+	Short: "Recommend requests and limits for containers based on their utilization",
+	Long: "Compares the utilization of each container to its requests and limits, flags over- and under-provisioned containers, " +
+		"and recommends new values. Supports table, json, yaml and patch output, the last producing snippets for kubectl patch.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := validateRecommendOutputType(opts.OutputFormat); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if opts.RequestHeadroom <= 0 || opts.LimitHeadroom <= 0 || opts.Tolerance < 0 {
+			fmt.Println("--request-headroom and --limit-headroom must be positive and --tolerance must not be negative")
+			os.Exit(1)
+		}
+
+		ctx := cmd.Context()
+		exitOnError(ctx, capacity.FetchAndRecommend(ctx, opts))
+	},
+}
+
+func validateRecommendOutputType(outputType string) error {
+	for _, format := range capacity.SupportedRecommendOutputs() {
+		if format == outputType {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unsupported Output Type. We only support: %v", capacity.SupportedRecommendOutputs())
+}
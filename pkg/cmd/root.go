@@ -15,8 +15,12 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/robscott/kube-capacity/pkg/capacity"
 	"github.com/spf13/cobra"
@@ -28,6 +32,22 @@ var rootCmd = &cobra.Command{
 	Use:   "kube-capacity",
 	Short: "kube-capacity provides an overview of the resource requests, limits, and utilization in a Kubernetes cluster.",
 	Long:  "kube-capacity provides an overview of the resource requests, limits, and utilization in a Kubernetes cluster.",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if opts.ChunkSize < 0 {
+			fmt.Println("--chunk-size must not be negative")
+			os.Exit(1)
+		}
+
+		if opts.FieldSelector != "" && len(opts.FromFiles) > 0 {
+			fmt.Println("--field-selector cannot be used with --from-file")
+			os.Exit(1)
+		}
+
+		if err := validateMetricsSource(opts); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := cmd.ParseFlags(args); err != nil {
 			fmt.Printf("Error parsing flags: %v", err)
@@ -38,7 +58,38 @@ var rootCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		capacity.FetchAndPrint(opts)
+		if err := validateGroupBy(opts.GroupBy); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if opts.GroupNodesBy != "" && opts.GroupBy != capacity.NodeGroup {
+			fmt.Println("--group-nodes-by can only be used when grouping by node")
+			os.Exit(1)
+		}
+
+		if opts.Watch && opts.OutputFormat != capacity.TableOutput {
+			fmt.Println("--watch can only be used with table output")
+			os.Exit(1)
+		}
+
+		if opts.Watch && opts.WatchInterval <= 0 {
+			fmt.Println("--interval must be positive")
+			os.Exit(1)
+		}
+
+		if err := validateSamples(opts); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if err := validateContexts(opts); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		ctx := cmd.Context()
+		exitOnError(ctx, capacity.FetchAndPrint(ctx, opts))
 	},
 }
 
@@ -49,12 +100,28 @@ func init() {
 		"pods", "p", false, "includes pods in output")
 	rootCmd.PersistentFlags().BoolVarP(&opts.ShowUtil,
 		"util", "u", false, "includes resource utilization in output")
+	rootCmd.PersistentFlags().StringVarP(&opts.MetricsSource,
+		"metrics-source", "", capacity.MetricsServerSource,
+		fmt.Sprintf("source of resource utilization (supports: %v)", capacity.SupportedMetricsSources()))
+	rootCmd.PersistentFlags().StringVarP(&opts.PrometheusURL,
+		"prometheus-url", "", "", "URL of the Prometheus server to query for utilization with --metrics-source prometheus")
+	rootCmd.PersistentFlags().StringVarP(&opts.Window,
+		"window", "", "7d", "window of time to summarize utilization over with --metrics-source prometheus")
+	rootCmd.PersistentFlags().StringVarP(&opts.Stat,
+		"stat", "", capacity.StatP95,
+		fmt.Sprintf("statistic to summarize utilization over the window with (supports: %v)", capacity.SupportedStats()))
 	rootCmd.PersistentFlags().BoolVarP(&opts.ShowPodCount,
 		"pod-count", "", false, "includes pod count per node in output")
+	rootCmd.PersistentFlags().BoolVarP(&opts.ShowPending,
+		"pending", "", false, "includes pods that have not been scheduled to a node in output")
+	rootCmd.PersistentFlags().BoolVarP(&opts.ShowClasses,
+		"classes", "", false, "includes the QoS and priority classes of pods in output")
 	rootCmd.PersistentFlags().BoolVarP(&opts.AvailableFormat,
 		"available", "a", false, "includes quantity available instead of percentage used")
 	rootCmd.PersistentFlags().StringVarP(&opts.PodLabels,
 		"pod-labels", "l", "", "labels to filter pods with")
+	rootCmd.PersistentFlags().StringVarP(&opts.FieldSelector,
+		"field-selector", "", "", "field selector to filter pods with (e.g. status.phase=Running), supports '=', '==', and '!='")
 	rootCmd.PersistentFlags().StringVarP(&opts.NodeLabels,
 		"node-labels", "", "", "labels to filter nodes with")
 	rootCmd.PersistentFlags().BoolVarP(&opts.ExcludeTainted,
@@ -71,9 +138,20 @@ func init() {
 		"kubeconfig", "", "", "kubeconfig file to use for Kubernetes config")
 	rootCmd.PersistentFlags().BoolVarP(&opts.InsecureSkipTLSVerify,
 		"insecure-skip-tls-verify", "", false, "If true, the server's certificate will not be checked for validity. This will make your HTTPS connections insecure")
+	rootCmd.PersistentFlags().StringVarP(&opts.RequestTimeout,
+		"request-timeout", "", "0", "The length of time to wait before giving up on a single server request. Non-zero values should contain a corresponding time unit (e.g. 1s, 2m, 3h). A value of zero means don't timeout requests.")
+	rootCmd.PersistentFlags().BoolVarP(&opts.Verbose,
+		"verbose", "", false, "print how long each request to the API took to stderr")
+	rootCmd.PersistentFlags().Int64VarP(&opts.ChunkSize,
+		"chunk-size", "", 500, "Return large lists in chunks rather than all at once. Pass 0 to disable.")
 	rootCmd.PersistentFlags().StringVarP(&opts.SortBy,
 		"sort", "", "name",
 		fmt.Sprintf("attribute to sort results by (supports: %v)", capacity.SupportedSortAttributes))
+	rootCmd.PersistentFlags().StringVarP(&opts.GroupBy,
+		"group-by", "", capacity.NodeGroup,
+		fmt.Sprintf("attribute to group results by (supports: %v)", capacity.SupportedGroupBys()))
+	rootCmd.PersistentFlags().StringVarP(&opts.GroupNodesBy,
+		"group-nodes-by", "", "", "node label key to aggregate nodes by (e.g. topology.kubernetes.io/zone)")
 	rootCmd.PersistentFlags().StringVarP(&opts.OutputFormat,
 		"output", "o", capacity.TableOutput,
 		fmt.Sprintf("output format for information (supports: %v)", capacity.SupportedOutputs()))
@@ -85,11 +163,36 @@ func init() {
 		"hide-requests", "", false, "hide requests from output")
 	rootCmd.PersistentFlags().BoolVarP(&opts.HideLimits,
 		"hide-limits", "", false, "hide limits from output")
+	rootCmd.Flags().BoolVarP(&opts.Watch,
+		"watch", "w", false, "refresh output periodically, highlighting values that changed")
+	rootCmd.Flags().DurationVarP(&opts.WatchInterval,
+		"interval", "", 2*time.Second, "time between refreshes when watching")
+	rootCmd.Flags().IntVarP(&opts.Samples,
+		"samples", "", 1, "number of times to sample utilization, reporting its average, maximum and 95th percentile when more than one")
+	rootCmd.Flags().DurationVarP(&opts.SampleInterval,
+		"sample-interval", "", 15*time.Second, "time between utilization samples")
+	rootCmd.Flags().StringSliceVarP(&opts.KubeContexts,
+		"contexts", "", []string{}, "comma separated list of contexts to gather data from concurrently, adding a cluster column and fleet totals to output")
+	rootCmd.Flags().BoolVarP(&opts.AllContexts,
+		"all-contexts", "", false, "gather data from every context in the Kubernetes config")
+	rootCmd.PersistentFlags().StringArrayVarP(&opts.FromFiles,
+		"from-file", "f", []string{}, "read pods, nodes, namespaces and metrics from a JSON or YAML file instead of a cluster, may be repeated (use - for stdin)")
+	rootCmd.PersistentFlags().StringSliceVarP(&opts.Resources,
+		"resources", "", []string{"cpu", "memory"}, "comma separated list of resources to include in output (e.g. cpu,memory,storage,hugepages-2Mi,nvidia.com/gpu)")
 }
 
 // Execute is the primary entrypoint for this CLI
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// The first interrupt cancels requests that are in flight so that
+	// partial results can be reported, while a second exits immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -103,3 +206,87 @@ func validateOutputType(outputType string) error {
 	}
 	return fmt.Errorf("Unsupported Output Type. We only support: %v", capacity.SupportedOutputs())
 }
+
+func validateGroupBy(groupBy string) error {
+	for _, group := range capacity.SupportedGroupBys() {
+		if group == groupBy {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unsupported Group By. We only support: %v", capacity.SupportedGroupBys())
+}
+
+func validateMetricsSource(opts capacity.Options) error {
+	supported := false
+	for _, source := range capacity.SupportedMetricsSources() {
+		if source == opts.MetricsSource {
+			supported = true
+		}
+	}
+	if !supported {
+		return fmt.Errorf("Unsupported Metrics Source. We only support: %v", capacity.SupportedMetricsSources())
+	}
+
+	switch opts.MetricsSource {
+	case capacity.KubeletSource:
+		if len(opts.FromFiles) > 0 {
+			return fmt.Errorf("--metrics-source kubelet cannot be used with --from-file")
+		}
+	case capacity.PrometheusSource:
+		if opts.PrometheusURL == "" {
+			return fmt.Errorf("--prometheus-url is required with --metrics-source prometheus")
+		}
+		if !capacity.ValidPrometheusWindow(opts.Window) {
+			return fmt.Errorf("--window must be a duration such as 7d or 12h")
+		}
+		if err := validateStat(opts.Stat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateStat(stat string) error {
+	for _, s := range capacity.SupportedStats() {
+		if s == stat {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unsupported Stat. We only support: %v", capacity.SupportedStats())
+}
+
+func validateSamples(opts capacity.Options) error {
+	switch {
+	case opts.Samples < 1:
+		return fmt.Errorf("--samples must be at least 1")
+	case opts.Samples == 1:
+		return nil
+	case opts.SampleInterval <= 0:
+		return fmt.Errorf("--sample-interval must be positive")
+	case !opts.ShowUtil:
+		return fmt.Errorf("--samples can only be used with --util")
+	case opts.Watch:
+		return fmt.Errorf("--samples cannot be used with --watch")
+	case opts.MetricsSource == capacity.PrometheusSource:
+		return fmt.Errorf("--samples cannot be used with --metrics-source prometheus")
+	}
+	return nil
+}
+
+func validateContexts(opts capacity.Options) error {
+	if len(opts.KubeContexts) == 0 && !opts.AllContexts {
+		return nil
+	}
+
+	switch {
+	case len(opts.KubeContexts) > 0 && opts.AllContexts:
+		return fmt.Errorf("--contexts and --all-contexts cannot be used together")
+	case opts.KubeContext != "":
+		return fmt.Errorf("--context cannot be used with --contexts or --all-contexts")
+	case len(opts.FromFiles) > 0:
+		return fmt.Errorf("--from-file cannot be used with --contexts or --all-contexts")
+	case opts.Watch:
+		return fmt.Errorf("--watch cannot be used with --contexts or --all-contexts")
+	}
+	return nil
+}
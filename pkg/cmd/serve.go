@@ -0,0 +1,49 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/robscott/kube-capacity/pkg/capacity"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	serveCmd.Flags().StringVarP(&opts.ListenAddress,
+		"listen-address", "", ":8080", "address to serve metrics on")
+	serveCmd.Flags().DurationVarP(&opts.RefreshInterval,
+		"refresh-interval", "", 30*time.Second, "time between refreshes of the metrics")
+	rootCmd.AddCommand(serveCmd)
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve resource requests, limits, and utilization as Prometheus metrics",
+	Long: "Runs an HTTP server exposing the resource requests, limits, and utilization of the cluster and each node " +
+		"in the Prometheus exposition format on /metrics, along with a health check on /healthz. " +
+		"Pods and containers are included with --pods and --containers.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if opts.RefreshInterval <= 0 {
+			fmt.Println("--refresh-interval must be positive")
+			os.Exit(1)
+		}
+
+		ctx := cmd.Context()
+		exitOnError(ctx, capacity.Serve(ctx, opts))
+	},
+}
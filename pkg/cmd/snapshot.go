@@ -0,0 +1,43 @@
+// Copyright 2026 Kube Capacity Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/robscott/kube-capacity/pkg/capacity"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	snapshotCmd.AddCommand(snapshotSaveCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save resource requests, limits, and utilization for later comparison",
+}
+
+var snapshotSaveCmd = &cobra.Command{
+	Use:   "save <file>",
+	Short: "Save resource requests, limits, and utilization to a file",
+	Long: "Saves the resource requests, limits, and utilization of the cluster and each node, pod, and container " +
+		"to a JSON file that can be compared with another snapshot using diff. " +
+		"Utilization is only included with --util.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		exitOnError(ctx, capacity.SaveSnapshot(ctx, opts, args[0]))
+	},
+}
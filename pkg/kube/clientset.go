@@ -15,6 +15,10 @@
 package kube
 
 import (
+	"net/http"
+	"sort"
+	"time"
+
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -26,8 +30,8 @@ import (
 )
 
 // NewClientSet returns a new Kubernetes clientset
-func NewClientSet(kubeContext, kubeConfig string, FlagInsecure bool, impersonateUser string, impersonateGroup string) (*kubernetes.Clientset, error) {
-	config, err := getKubeConfig(kubeContext, kubeConfig, FlagInsecure)
+func NewClientSet(kubeContext, kubeConfig string, FlagInsecure bool, impersonateUser string, impersonateGroup string, requestTimeout string) (*kubernetes.Clientset, error) {
+	config, err := getKubeConfig(kubeContext, kubeConfig, FlagInsecure, requestTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -46,8 +50,8 @@ func NewClientSet(kubeContext, kubeConfig string, FlagInsecure bool, impersonate
 }
 
 // NewMetricsClientSet returns a new clientset for Kubernetes metrics
-func NewMetricsClientSet(kubeContext, kubeConfig string, FlagInsecure bool) (*metrics.Clientset, error) {
-	config, err := getKubeConfig(kubeContext, kubeConfig, FlagInsecure)
+func NewMetricsClientSet(kubeContext, kubeConfig string, FlagInsecure bool, requestTimeout string) (*metrics.Clientset, error) {
+	config, err := getKubeConfig(kubeContext, kubeConfig, FlagInsecure, requestTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -55,13 +59,50 @@ func NewMetricsClientSet(kubeContext, kubeConfig string, FlagInsecure bool) (*me
 	return metrics.NewForConfig(config)
 }
 
-func getKubeConfig(kubeContext, kubeConfig string, insecureSkipTLSVerify bool) (*rest.Config, error) {
+// NewHTTPClient returns a client for HTTP APIs outside of Kubernetes, with
+// a request timeout parsed as kubectl's --request-timeout is
+func NewHTTPClient(requestTimeout string) (*http.Client, error) {
+	timeout := time.Duration(0)
+	if requestTimeout != "" {
+		var err error
+		timeout, err = clientcmd.ParseTimeout(requestTimeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &http.Client{Timeout: timeout}, nil
+}
+
+// ContextNames returns the names of the contexts in the Kubernetes config,
+// in alphabetical order
+func ContextNames(kubeConfig string) ([]string, error) {
+	rawConfig, err := newClientConfig("", kubeConfig, false, "").RawConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(rawConfig.Contexts))
+	for name := range rawConfig.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func getKubeConfig(kubeContext, kubeConfig string, insecureSkipTLSVerify bool, requestTimeout string) (*rest.Config, error) {
+	return newClientConfig(kubeContext, kubeConfig, insecureSkipTLSVerify, requestTimeout).ClientConfig()
+}
+
+// newClientConfig returns the config for a context. The request timeout is
+// parsed as kubectl's --request-timeout is, with "" or "0" meaning requests
+// do not time out.
+func newClientConfig(kubeContext, kubeConfig string, insecureSkipTLSVerify bool, requestTimeout string) clientcmd.ClientConfig {
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
 	if kubeConfig != "" {
 		loadingRules.ExplicitPath = kubeConfig
 	}
 	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
 		loadingRules,
-		&clientcmd.ConfigOverrides{ClusterInfo: clientcmdapi.Cluster{InsecureSkipTLSVerify: insecureSkipTLSVerify}, CurrentContext: kubeContext},
-	).ClientConfig()
+		&clientcmd.ConfigOverrides{ClusterInfo: clientcmdapi.Cluster{InsecureSkipTLSVerify: insecureSkipTLSVerify}, CurrentContext: kubeContext, Timeout: requestTimeout},
+	)
 }